@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"math"
-	"time"
 
 	"github.com/go-gl/mathgl/mgl32"
 )
@@ -73,11 +72,11 @@ func CurveVal(curve *Curve, rate float32) float32 {
 }
 
 type AttachmentAnimUpdate struct {
-	Slot      *Slot
+	Slot      *SlotState
 	KeyFrames []*KeyFrame // 至少 1 个
 }
 
-func NewAttachmentAnimUpdate(slot *Slot, keyFrames []*KeyFrame) *AttachmentAnimUpdate {
+func NewAttachmentAnimUpdate(slot *SlotState, keyFrames []*KeyFrame) *AttachmentAnimUpdate {
 	return &AttachmentAnimUpdate{Slot: slot, KeyFrames: keyFrames}
 }
 
@@ -87,89 +86,115 @@ func (a *AttachmentAnimUpdate) Update(curr float32) {
 }
 
 type RotateAnimUpdate struct {
-	Bone      *Bone
+	Bone      *BoneNode
 	KeyFrames []*KeyFrame
 }
 
 func (r *RotateAnimUpdate) Update(curr float32) {
 	idx := GetIndexByTime(r.KeyFrames, curr)
 	if idx < 0 {
-		r.Bone.LocalRotate = AdjustRotate(r.Bone.Rotate + r.KeyFrames[0].Rotate)
+		r.Bone.LocalRotate = AdjustRotate(r.Bone.Bone.Rotate + r.KeyFrames[0].Rotate)
 	} else if idx+1 >= len(r.KeyFrames) {
-		r.Bone.LocalRotate = AdjustRotate(r.Bone.Rotate + r.KeyFrames[idx].Rotate)
+		r.Bone.LocalRotate = AdjustRotate(r.Bone.Bone.Rotate + r.KeyFrames[idx].Rotate)
 	} else {
 		pre := r.KeyFrames[idx]
 		next := r.KeyFrames[idx+1]
 		rate := CurveVal(pre.Curve, (curr-pre.Time)/(next.Time-pre.Time))
-		r.Bone.LocalRotate = AdjustRotate(r.Bone.Rotate + LerpRotate(pre.Rotate, next.Rotate, rate))
+		r.Bone.LocalRotate = AdjustRotate(r.Bone.Bone.Rotate + LerpRotate(pre.Rotate, next.Rotate, rate))
 	}
 }
 
-func NewRotateAnimUpdate(bone *Bone, keyFrames []*KeyFrame) *RotateAnimUpdate {
+func NewRotateAnimUpdate(bone *BoneNode, keyFrames []*KeyFrame) *RotateAnimUpdate {
 	return &RotateAnimUpdate{Bone: bone, KeyFrames: keyFrames}
 }
 
 type TranslateAnimUpdate struct {
-	Bone      *Bone
+	Bone      *BoneNode
 	KeyFrames []*KeyFrame
 }
 
-func NewTranslateAnimUpdate(bone *Bone, keyFrames []*KeyFrame) *TranslateAnimUpdate {
+func NewTranslateAnimUpdate(bone *BoneNode, keyFrames []*KeyFrame) *TranslateAnimUpdate {
 	return &TranslateAnimUpdate{Bone: bone, KeyFrames: keyFrames}
 }
 
 func (t *TranslateAnimUpdate) Update(curr float32) {
 	idx := GetIndexByTime(t.KeyFrames, curr)
 	if idx < 0 {
-		t.Bone.LocalPos = t.Bone.Pos.Add(t.KeyFrames[0].Offset)
+		t.Bone.LocalPos = t.Bone.Bone.Pos.Add(t.KeyFrames[0].Offset)
 	} else if idx+1 >= len(t.KeyFrames) {
-		t.Bone.LocalPos = t.Bone.Pos.Add(t.KeyFrames[idx].Offset)
+		t.Bone.LocalPos = t.Bone.Bone.Pos.Add(t.KeyFrames[idx].Offset)
 	} else {
 		pre := t.KeyFrames[idx]
 		next := t.KeyFrames[idx+1]
 		rate := CurveVal(pre.Curve, (curr-pre.Time)/(next.Time-pre.Time))
-		t.Bone.LocalPos = t.Bone.Pos.Add(Vec2Lerp(pre.Offset, next.Offset, rate))
+		t.Bone.LocalPos = t.Bone.Bone.Pos.Add(Vec2Lerp(pre.Offset, next.Offset, rate))
 	}
 }
 
 type ScaleAnimUpdate struct {
-	Bone      *Bone
+	Bone      *BoneNode
 	KeyFrames []*KeyFrame
 }
 
-func NewScaleAnimUpdate(bone *Bone, keyFrames []*KeyFrame) *ScaleAnimUpdate {
+func NewScaleAnimUpdate(bone *BoneNode, keyFrames []*KeyFrame) *ScaleAnimUpdate {
 	return &ScaleAnimUpdate{Bone: bone, KeyFrames: keyFrames}
 }
 
 func (t *ScaleAnimUpdate) Update(curr float32) {
 	idx := GetIndexByTime(t.KeyFrames, curr)
 	if idx < 0 {
-		t.Bone.LocalScale = Vec2Mul(t.Bone.Scale, t.KeyFrames[0].Scale)
+		t.Bone.LocalScale = Vec2Mul(t.Bone.Bone.Scale, t.KeyFrames[0].Scale)
 	} else if idx+1 >= len(t.KeyFrames) {
-		t.Bone.LocalScale = Vec2Mul(t.Bone.Scale, t.KeyFrames[idx].Scale)
+		t.Bone.LocalScale = Vec2Mul(t.Bone.Bone.Scale, t.KeyFrames[idx].Scale)
 	} else {
 		pre := t.KeyFrames[idx]
 		next := t.KeyFrames[idx+1]
 		rate := CurveVal(pre.Curve, (curr-pre.Time)/(next.Time-pre.Time))
-		t.Bone.LocalScale = Vec2Mul(t.Bone.Scale, Vec2Lerp(pre.Scale, next.Scale, rate))
+		t.Bone.LocalScale = Vec2Mul(t.Bone.Bone.Scale, Vec2Lerp(pre.Scale, next.Scale, rate))
+	}
+}
+
+type ShearAnimUpdate struct {
+	Bone      *BoneNode
+	KeyFrames []*KeyFrame
+}
+
+func NewShearAnimUpdate(bone *BoneNode, keyFrames []*KeyFrame) *ShearAnimUpdate {
+	return &ShearAnimUpdate{Bone: bone, KeyFrames: keyFrames}
+}
+
+func (t *ShearAnimUpdate) Update(curr float32) {
+	idx := GetIndexByTime(t.KeyFrames, curr)
+	if idx < 0 {
+		t.Bone.LocalShear = t.Bone.Bone.Shear.Add(t.KeyFrames[0].Shear)
+	} else if idx+1 >= len(t.KeyFrames) {
+		t.Bone.LocalShear = t.Bone.Bone.Shear.Add(t.KeyFrames[idx].Shear)
+	} else {
+		pre := t.KeyFrames[idx]
+		next := t.KeyFrames[idx+1]
+		rate := CurveVal(pre.Curve, (curr-pre.Time)/(next.Time-pre.Time))
+		t.Bone.LocalShear = t.Bone.Bone.Shear.Add(Vec2Lerp(pre.Shear, next.Shear, rate))
 	}
 }
 
 type DeformAnimUpdate struct {
-	Attachment *Attachment
-	KeyFrames  []*KeyFrame
+	Attachment  *Attachment
+	Attachments []*AttachmentState // 实际被修改的附件，包含继承变形的链接网格
+	KeyFrames   []*KeyFrame
 }
 
 func (d *DeformAnimUpdate) setDeform(deform []mgl32.Vec2, weightDeform [][]mgl32.Vec2) {
-	if d.Attachment.Weight {
-		for i, items := range d.Attachment.CurrWeightVertices {
-			for j, item := range items {
-				item.Offset = item.Offset.Add(weightDeform[i][j])
+	for _, attachment := range d.Attachments {
+		if attachment.Attachment.Weight {
+			for i, items := range attachment.CurrWeightVertices {
+				for j, item := range items {
+					item.Offset = item.Offset.Add(weightDeform[i][j])
+				}
+			}
+		} else {
+			for i := 0; i < len(attachment.CurrVertices); i++ {
+				attachment.CurrVertices[i] = attachment.CurrVertices[i].Add(deform[i])
 			}
-		}
-	} else {
-		for i := 0; i < len(d.Attachment.CurrVertices); i++ {
-			d.Attachment.CurrVertices[i] = d.Attachment.CurrVertices[i].Add(deform[i])
 		}
 	}
 }
@@ -203,12 +228,12 @@ func (d *DeformAnimUpdate) Update(curr float32) {
 	}
 }
 
-func NewDeformAnimUpdate(attachment *Attachment, keyFrames []*KeyFrame) *DeformAnimUpdate {
-	return &DeformAnimUpdate{Attachment: attachment, KeyFrames: keyFrames}
+func NewDeformAnimUpdate(attachment *Attachment, attachments []*AttachmentState, keyFrames []*KeyFrame) *DeformAnimUpdate {
+	return &DeformAnimUpdate{Attachment: attachment, Attachments: attachments, KeyFrames: keyFrames}
 }
 
 type DrawOrderAnimUpdate struct {
-	Slots     []*Slot
+	Slots     []*SlotState
 	KeyFrames []*KeyFrame
 }
 
@@ -220,12 +245,12 @@ func (d *DrawOrderAnimUpdate) Update(curr float32) {
 	}
 }
 
-func NewDrawOrderAnimUpdate(slots []*Slot, keyFrames []*KeyFrame) *DrawOrderAnimUpdate {
+func NewDrawOrderAnimUpdate(slots []*SlotState, keyFrames []*KeyFrame) *DrawOrderAnimUpdate {
 	return &DrawOrderAnimUpdate{Slots: slots, KeyFrames: keyFrames}
 }
 
 type ColorAnimUpdate struct {
-	Slot      *Slot
+	Slot      *SlotState
 	KeyFrames []*KeyFrame
 }
 
@@ -243,12 +268,12 @@ func (c *ColorAnimUpdate) Update(curr float32) {
 	}
 }
 
-func NewColorAnimUpdate(slot *Slot, keyFrames []*KeyFrame) *ColorAnimUpdate {
+func NewColorAnimUpdate(slot *SlotState, keyFrames []*KeyFrame) *ColorAnimUpdate {
 	return &ColorAnimUpdate{Slot: slot, KeyFrames: keyFrames}
 }
 
 type TransformConstraintAnimUpdate struct {
-	TransformConstraint *TransformConstraint
+	TransformConstraint *TransformConstraintState
 	KeyFrames           []*KeyFrame
 }
 
@@ -258,10 +283,12 @@ func (t *TransformConstraintAnimUpdate) Update(curr float32) {
 		t.TransformConstraint.CurrRotateMix = t.KeyFrames[0].RotateMix
 		t.TransformConstraint.CurrOffsetMix = t.KeyFrames[0].OffsetMix
 		t.TransformConstraint.CurrScaleMix = t.KeyFrames[0].ScaleMix
+		t.TransformConstraint.CurrShearMix = t.KeyFrames[0].ShearMix
 	} else if idx+1 >= len(t.KeyFrames) {
 		t.TransformConstraint.CurrRotateMix = t.KeyFrames[idx].RotateMix
 		t.TransformConstraint.CurrOffsetMix = t.KeyFrames[idx].OffsetMix
 		t.TransformConstraint.CurrScaleMix = t.KeyFrames[idx].ScaleMix
+		t.TransformConstraint.CurrShearMix = t.KeyFrames[idx].ShearMix
 	} else {
 		pre := t.KeyFrames[idx]
 		next := t.KeyFrames[idx+1]
@@ -269,15 +296,49 @@ func (t *TransformConstraintAnimUpdate) Update(curr float32) {
 		t.TransformConstraint.CurrRotateMix = Lerp(pre.RotateMix, next.RotateMix, rate)
 		t.TransformConstraint.CurrOffsetMix = Lerp(pre.OffsetMix, next.OffsetMix, rate)
 		t.TransformConstraint.CurrScaleMix = Lerp(pre.ScaleMix, next.ScaleMix, rate)
+		t.TransformConstraint.CurrShearMix = Lerp(pre.ShearMix, next.ShearMix, rate)
 	}
 }
 
-func NewTransformConstraintAnimUpdate(transformConstraint *TransformConstraint, keyFrames []*KeyFrame) *TransformConstraintAnimUpdate {
+func NewTransformConstraintAnimUpdate(transformConstraint *TransformConstraintState, keyFrames []*KeyFrame) *TransformConstraintAnimUpdate {
 	return &TransformConstraintAnimUpdate{TransformConstraint: transformConstraint, KeyFrames: keyFrames}
 }
 
+type IkConstraintAnimUpdate struct {
+	IkConstraint *IkConstraintState
+	KeyFrames    []*KeyFrame
+}
+
+func (t *IkConstraintAnimUpdate) Update(curr float32) {
+	idx := GetIndexByTime(t.KeyFrames, curr)
+	if idx < 0 {
+		t.setKeyFrame(t.KeyFrames[0])
+	} else if idx+1 >= len(t.KeyFrames) {
+		t.setKeyFrame(t.KeyFrames[idx])
+	} else {
+		pre := t.KeyFrames[idx]
+		next := t.KeyFrames[idx+1]
+		rate := CurveVal(pre.Curve, (curr-pre.Time)/(next.Time-pre.Time))
+		t.setKeyFrame(pre) // 非数值类型的直接使用前一帧的
+		t.IkConstraint.CurrMix = Lerp(pre.Mix, next.Mix, rate)
+		t.IkConstraint.CurrSoftness = Lerp(pre.Softness, next.Softness, rate)
+	}
+}
+
+func (t *IkConstraintAnimUpdate) setKeyFrame(keyFrame *KeyFrame) {
+	t.IkConstraint.CurrMix = keyFrame.Mix
+	t.IkConstraint.CurrSoftness = keyFrame.Softness
+	t.IkConstraint.CurrBendDirection = keyFrame.BendDirection
+	t.IkConstraint.CurrCompress = keyFrame.Compress
+	t.IkConstraint.CurrStretch = keyFrame.Stretch
+}
+
+func NewIkConstraintAnimUpdate(ikConstraint *IkConstraintState, keyFrames []*KeyFrame) *IkConstraintAnimUpdate {
+	return &IkConstraintAnimUpdate{IkConstraint: ikConstraint, KeyFrames: keyFrames}
+}
+
 type TwoColorAnimUpdate struct {
-	Slot      *Slot
+	Slot      *SlotState
 	KeyFrames []*KeyFrame
 }
 
@@ -298,12 +359,12 @@ func (c *TwoColorAnimUpdate) Update(curr float32) {
 	}
 }
 
-func NewTwoColorAnimUpdate(slot *Slot, keyFrames []*KeyFrame) *TwoColorAnimUpdate {
+func NewTwoColorAnimUpdate(slot *SlotState, keyFrames []*KeyFrame) *TwoColorAnimUpdate {
 	return &TwoColorAnimUpdate{Slot: slot, KeyFrames: keyFrames}
 }
 
 type PathPositionAnimUpdate struct {
-	PathConstraint *PathConstraint
+	PathConstraint *PathConstraintState
 	KeyFrames      []*KeyFrame
 }
 
@@ -321,12 +382,12 @@ func (t *PathPositionAnimUpdate) Update(curr float32) {
 	}
 }
 
-func NewPathPositionAnimUpdate(pathConstraint *PathConstraint, keyFrames []*KeyFrame) *PathPositionAnimUpdate {
+func NewPathPositionAnimUpdate(pathConstraint *PathConstraintState, keyFrames []*KeyFrame) *PathPositionAnimUpdate {
 	return &PathPositionAnimUpdate{PathConstraint: pathConstraint, KeyFrames: keyFrames}
 }
 
 type PathSpaceAnimUpdate struct {
-	PathConstraint *PathConstraint
+	PathConstraint *PathConstraintState
 	KeyFrames      []*KeyFrame
 }
 
@@ -344,12 +405,12 @@ func (t *PathSpaceAnimUpdate) Update(curr float32) {
 	}
 }
 
-func NewPathSpaceAnimUpdate(pathConstraint *PathConstraint, keyFrames []*KeyFrame) *PathSpaceAnimUpdate {
+func NewPathSpaceAnimUpdate(pathConstraint *PathConstraintState, keyFrames []*KeyFrame) *PathSpaceAnimUpdate {
 	return &PathSpaceAnimUpdate{PathConstraint: pathConstraint, KeyFrames: keyFrames}
 }
 
 type PathMixAnimUpdate struct {
-	PathConstraint *PathConstraint
+	PathConstraint *PathConstraintState
 	KeyFrames      []*KeyFrame
 }
 
@@ -370,24 +431,176 @@ func (t *PathMixAnimUpdate) Update(curr float32) {
 	}
 }
 
-func NewPathMixAnimUpdate(pathConstraint *PathConstraint, keyFrames []*KeyFrame) *PathMixAnimUpdate {
+func NewPathMixAnimUpdate(pathConstraint *PathConstraintState, keyFrames []*KeyFrame) *PathMixAnimUpdate {
 	return &PathMixAnimUpdate{PathConstraint: pathConstraint, KeyFrames: keyFrames}
 }
 
+// 事件不作用于骨骼，由 AnimController 推进时间时按经过的区间触发
+type EventAnimUpdate struct {
+	KeyFrames []*KeyFrame
+	Fire      func(event *Event)
+}
+
+// 正放触发 (start, end] 内的事件，倒放 start > end 时按时间从后往前触发 [end, start) 内的事件
+func (e *EventAnimUpdate) fire(start, end float32) {
+	if start <= end {
+		for _, keyFrame := range e.KeyFrames {
+			if keyFrame.Time > start && keyFrame.Time <= end {
+				e.Fire(keyFrame.Event)
+			}
+		}
+		return
+	}
+	for i := len(e.KeyFrames) - 1; i >= 0; i-- {
+		if keyFrame := e.KeyFrames[i]; keyFrame.Time >= end && keyFrame.Time < start {
+			e.Fire(keyFrame.Event)
+		}
+	}
+}
+
+func NewEventAnimUpdate(keyFrames []*KeyFrame, fire func(event *Event)) *EventAnimUpdate {
+	return &EventAnimUpdate{KeyFrames: keyFrames, Fire: fire}
+}
+
+type EventListener func(event *Event)
+
+const (
+	PlayLoop     = 0 // 循环播放
+	PlayPingPong = 1 // 播放到一端后反向播放
+	PlayOnce     = 2 // 只播放一次，停在最后一帧
+)
+
 type AnimController struct {
 	AnimName    string
 	Duration    float32
-	Start       time.Time
+	Time        float32 // 当前播放到的时间，范围 [0, Duration]
+	TimeScale   float32 // 播放速度，1 为原速，负数倒放
+	Mode        int
+	Paused      bool
+	Backward    bool // 往返播放时正处于反向的半程
 	AnimUpdates []IAnimUpdate
+	Events      *EventAnimUpdate // 动画没有事件时为 nil
+	Listeners   []EventListener
+	fireCurr    bool // 刚创建或跳转后，当前时刻上的事件还没有触发
 }
 
-func (c *AnimController) Update() {
-	curr := float32(time.Now().Sub(c.Start).Seconds())
+func (c *AnimController) AddEventListener(listener EventListener) {
+	c.Listeners = append(c.Listeners, listener)
+}
+
+func (c *AnimController) fireEvent(event *Event) {
+	for _, listener := range c.Listeners {
+		listener(event)
+	}
+}
+
+// 推进 delta 秒（会乘上 TimeScale）并应用当前时刻的动画，暂停时只应用当前帧
+// 每帧都要在 SetToSetupPose 之后调用
+func (c *AnimController) Update(delta float32) {
+	if !c.Paused {
+		c.advance(delta * c.TimeScale)
+	}
+	c.Apply()
+}
+
+// 应用当前时刻的动画，不推进时间也不触发事件
+func (c *AnimController) Apply() {
 	for _, update := range c.AnimUpdates {
-		update.Update(curr)
+		update.Update(c.Time)
+	}
+}
+
+// 跳转到 curr 秒，跳过的事件不会触发，curr 时刻上的事件在之后推进时触发
+func (c *AnimController) SetTime(curr float32) {
+	c.Time = min(max(curr, 0), c.Duration)
+	c.fireCurr = true
+}
+
+// 只播放一次的动画是否已经停在最后一帧，倒放时最后一帧是 0
+func (c *AnimController) IsFinished() bool {
+	if c.Mode != PlayOnce {
+		return false
 	}
-	if curr > c.Duration { // 循环播放
-		c.Start = time.Now()
+	if c.TimeScale < 0 {
+		return c.Time <= 0
+	}
+	return c.Time >= c.Duration
+}
+
+func (c *AnimController) advance(step float32) {
+	if c.Duration <= 0 || step == 0 {
+		return
+	}
+	if c.Mode == PlayPingPong && c.Backward {
+		step = -step
+	}
+	from := c.Time
+	if c.fireCurr { // 往播放方向的反方向挪一点，让 (from, to] 包含 from
+		from = math.Nextafter32(from, float32(math.Copysign(math.MaxFloat32, float64(-step))))
+		c.fireCurr = false
+	}
+	switch c.Mode {
+	case PlayOnce:
+		to := min(max(c.Time+step, 0), c.Duration)
+		c.fire(from, to)
+		c.Time = to
+	case PlayPingPong:
+		cycles := int(math.Floor(math.Abs(float64(step)) / float64(c.Duration*2))) // 一次跨过多轮时每轮往返的事件都要触发
+		for i := 0; i < cycles; i++ {
+			if step > 0 {
+				c.fire(from, c.Duration)
+				c.fire(c.Duration, 0)
+				c.fire(0, c.Time)
+			} else {
+				c.fire(from, 0)
+				c.fire(0, c.Duration)
+				c.fire(c.Duration, c.Time)
+			}
+			from = c.Time
+		}
+		step = float32(math.Mod(float64(step), float64(c.Duration*2)))
+		to := c.Time + step
+		for to > c.Duration || to < 0 { // 到达一端后折返，端点上的事件只触发一次
+			if to > c.Duration {
+				c.fire(from, c.Duration)
+				from, to = c.Duration, c.Duration*2-to
+			} else {
+				c.fire(from, 0)
+				from, to = 0, -to
+			}
+			c.Backward = !c.Backward
+		}
+		c.fire(from, to)
+		c.Time = to
+	default:
+		cycles := int(math.Floor(math.Abs(float64(step)) / float64(c.Duration))) // 一次跨过多轮时每轮的事件都要触发
+		for i := 0; i < cycles; i++ {
+			if step > 0 {
+				c.fire(from, math.MaxFloat32)
+				c.fire(-1, c.Time)
+			} else {
+				c.fire(from, -1)
+				c.fire(math.MaxFloat32, c.Time)
+			}
+			from = c.Time
+		}
+		step = float32(math.Mod(float64(step), float64(c.Duration)))
+		to := c.Time + step
+		if to > c.Duration { // 先把这一轮剩余的事件触发完，新一轮从 0 开始
+			c.fire(from, math.MaxFloat32)
+			from, to = -1, to-c.Duration
+		} else if to < 0 {
+			c.fire(from, -1)
+			from, to = math.MaxFloat32, to+c.Duration
+		}
+		c.fire(from, to)
+		c.Time = to
+	}
+}
+
+func (c *AnimController) fire(start, end float32) {
+	if c.Events != nil {
+		c.Events.fire(start, end)
 	}
 }
 
@@ -395,7 +608,9 @@ func (c *AnimController) GetAnimName() string {
 	return c.AnimName
 }
 
-func NewAnimController(anim *Animation, skel *Skel, attachments map[string]*AttachmentItem) *AnimController {
+// 动画作用于 skeleton 实例，同一份 SkeletonData 的多个实例互不影响
+func NewAnimController(anim *Animation, skeleton *Skeleton) *AnimController {
+	res := &AnimController{AnimName: anim.Name, Duration: anim.Duration, TimeScale: 1, fireCurr: true}
 	updates := make([]IAnimUpdate, 0)
 	for i, timeline := range anim.Timelines {
 		if len(timeline.KeyFrames) == 0 {
@@ -404,35 +619,44 @@ func NewAnimController(anim *Animation, skel *Skel, attachments map[string]*Atta
 		}
 		switch timeline.Type {
 		case TimelineAttachment:
-			updates = append(updates, NewAttachmentAnimUpdate(skel.Slots[timeline.Slot], timeline.KeyFrames))
+			updates = append(updates, NewAttachmentAnimUpdate(skeleton.Slots[timeline.Slot], timeline.KeyFrames))
 		case TimelineRotate:
-			updates = append(updates, NewRotateAnimUpdate(skel.Bones[timeline.Bone], timeline.KeyFrames))
+			updates = append(updates, NewRotateAnimUpdate(skeleton.Bones[timeline.Bone], timeline.KeyFrames))
 		case TimelineTranslate:
-			updates = append(updates, NewTranslateAnimUpdate(skel.Bones[timeline.Bone], timeline.KeyFrames))
+			updates = append(updates, NewTranslateAnimUpdate(skeleton.Bones[timeline.Bone], timeline.KeyFrames))
 		case TimelineScale:
-			updates = append(updates, NewScaleAnimUpdate(skel.Bones[timeline.Bone], timeline.KeyFrames))
-		case TimelineDeform:
-			attachment := attachments[AttachmentKey(timeline.Attachment, timeline.Slot)]
-			updates = append(updates, NewDeformAnimUpdate(attachment.Attachment, timeline.KeyFrames))
+			updates = append(updates, NewScaleAnimUpdate(skeleton.Bones[timeline.Bone], timeline.KeyFrames))
+		case TimelineDeform: // 只修改对应皮肤中的附件，不影响其他皮肤
+			attachment := skeleton.Data.Skins[timeline.Skin].GetAttachment(timeline.Slot, timeline.Attachment)
+			deforms := make([]*AttachmentState, 0)
+			for _, item := range skeleton.Data.GetDeformAttachments(attachment) {
+				deforms = append(deforms, skeleton.GetDeform(item))
+			}
+			updates = append(updates, NewDeformAnimUpdate(attachment, deforms, timeline.KeyFrames))
 		case TimelineDrawOrder:
-			updates = append(updates, NewDrawOrderAnimUpdate(skel.Slots, timeline.KeyFrames))
+			updates = append(updates, NewDrawOrderAnimUpdate(skeleton.Slots, timeline.KeyFrames))
 		case TimelineColor:
-			updates = append(updates, NewColorAnimUpdate(skel.Slots[timeline.Slot], timeline.KeyFrames))
+			updates = append(updates, NewColorAnimUpdate(skeleton.Slots[timeline.Slot], timeline.KeyFrames))
 		case TimelineTwoColor:
-			updates = append(updates, NewTwoColorAnimUpdate(skel.Slots[timeline.Slot], timeline.KeyFrames))
+			updates = append(updates, NewTwoColorAnimUpdate(skeleton.Slots[timeline.Slot], timeline.KeyFrames))
+		case TimelineIkConstraint:
+			updates = append(updates, NewIkConstraintAnimUpdate(skeleton.IkConstraints[timeline.IkConstraint], timeline.KeyFrames))
 		case TimelineTransformConstraint:
-			updates = append(updates, NewTransformConstraintAnimUpdate(skel.TransformConstraints[timeline.TransformConstraint], timeline.KeyFrames))
+			updates = append(updates, NewTransformConstraintAnimUpdate(skeleton.TransformConstraints[timeline.TransformConstraint], timeline.KeyFrames))
 		case TimelinePathConstraintPosition:
-			updates = append(updates, NewPathPositionAnimUpdate(skel.PathConstraints[timeline.PathConstraint], timeline.KeyFrames))
+			updates = append(updates, NewPathPositionAnimUpdate(skeleton.PathConstraints[timeline.PathConstraint], timeline.KeyFrames))
 		case TimelinePathConstraintSpace:
-			updates = append(updates, NewPathSpaceAnimUpdate(skel.PathConstraints[timeline.PathConstraint], timeline.KeyFrames))
+			updates = append(updates, NewPathSpaceAnimUpdate(skeleton.PathConstraints[timeline.PathConstraint], timeline.KeyFrames))
 		case TimelinePathConstraintMix:
-			updates = append(updates, NewPathMixAnimUpdate(skel.PathConstraints[timeline.PathConstraint], timeline.KeyFrames))
+			updates = append(updates, NewPathMixAnimUpdate(skeleton.PathConstraints[timeline.PathConstraint], timeline.KeyFrames))
 		case TimelineShear:
-		// 先不处理斜切，基本没有斜切的
+			updates = append(updates, NewShearAnimUpdate(skeleton.Bones[timeline.Bone], timeline.KeyFrames))
+		case TimelineEvent:
+			res.Events = NewEventAnimUpdate(timeline.KeyFrames, res.fireEvent) // 事件随时间推进触发，不随 Apply 触发
 		default:
 			panic("unknown timeline type")
 		}
 	}
-	return &AnimController{AnimName: anim.Name, Duration: anim.Duration, Start: time.Now(), AnimUpdates: updates}
+	res.AnimUpdates = updates
+	return res
 }
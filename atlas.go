@@ -2,7 +2,13 @@ package main
 
 import (
 	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/fs"
 	"os"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -29,28 +35,73 @@ type Atlas struct {
 	Header *AtlasHeader
 	Items  []*AtlasItem
 	Image  string
+	FS     fs.FS // 加载 Image 使用，为 nil 时从本地路径加载
 }
 
+func (a *Atlas) LoadImage() (image.Image, error) {
+	var file io.ReadCloser
+	var err error
+	if a.FS != nil {
+		file, err = a.FS.Open(a.Image)
+	} else {
+		file, err = os.Open(a.Image)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return png.Decode(file)
+}
+
+// path 为本地路径，失败 panic
 func ParseAtlas(path string) *Atlas {
-	bs, err := os.ReadFile(BasePath + path)
+	res, err := LoadAtlasFS(os.DirFS(filepath.Dir(path)), filepath.Base(path))
 	HandleErr(err)
+	return res
+}
+
+// 从 fsys 中加载，可以是目录、embed.FS 或 zip.Reader，图片路径相对于 atlas 文件
+func LoadAtlasFS(fsys fs.FS, name string) (*Atlas, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	res, err := LoadAtlas(file)
+	if err != nil {
+		return nil, err
+	}
+	res.FS = fsys
+	res.Image = path.Join(path.Dir(name), res.Image)
+	return res, nil
+}
+
+// 解析失败返回 *ParseError，Image 为 atlas 中记录的图片名，相对于当前工作目录加载
+func LoadAtlas(r io.Reader) (res *Atlas, err error) {
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &ParseError{Section: "header", Err: err}
+	}
 	lines := strings.Split(string(bs), "\n")
+	section, line := "header", 1
+	defer recoverParseErr(&err, func() (string, int64) {
+		offset := 0 // 出错行的起始偏移
+		for _, item := range lines[:min(line, len(lines))] {
+			offset += len(item) + 1
+		}
+		return section, int64(offset)
+	})
 	header := parseAtlasHeader(lines[1:6])
-	items := parseAtlasItems(lines[6:])
-	index := strings.LastIndex(path, "/")
+	items := make([]*AtlasItem, 0)
+	for line = 6; line+7 <= len(lines); line += 7 {
+		section = "region:" + strings.TrimSpace(lines[line])
+		items = append(items, parseAtlasItem(lines[line:line+7]))
+	}
 	return &Atlas{
 		Header: header,
 		Items:  items,
-		Image:  path[:index+1] + header.Image,
-	}
-}
-
-func parseAtlasItems(items []string) []*AtlasItem {
-	res := make([]*AtlasItem, 0)
-	for i := 0; i+7 <= len(items); i += 7 {
-		res = append(res, parseAtlasItem(items[i:i+7]))
-	}
-	return res
+		Image:  header.Image,
+	}, nil
 }
 
 func parseAtlasItem(items []string) *AtlasItem {
@@ -83,7 +134,7 @@ func parseAtlasItem(items []string) *AtlasItem {
 func parseRotate(item string) int {
 	item = strings.TrimSpace(item)
 	if !strings.HasPrefix(item, "rotate") {
-		panic(fmt.Sprintf("%s is not a valid rotate", item))
+		panic(fmt.Errorf("%s is not a valid rotate", item))
 	}
 	item = strings.TrimSpace(item[8:])
 	res, err := strconv.ParseBool(item) // 先尝试 bool 值
@@ -117,7 +168,7 @@ func parseAtlasHeader(items []string) *AtlasHeader {
 func parseStrList(line string, name string) []string {
 	line = strings.TrimSpace(line)
 	if !strings.HasPrefix(line, name) {
-		panic(fmt.Sprintf("%s not start with %s", line, name))
+		panic(fmt.Errorf("%s not start with %s", line, name))
 	}
 	items := strings.Split(line[len(name)+2:], ",")
 	res := make([]string, 0)
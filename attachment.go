@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+// 计算有顶点附件的世界坐标，非权重顶点跟随插槽的骨骼，deform 不为 nil 时使用动画修改后的顶点数据
+func (a *Attachment) ComputeWorldVertices(bones []*BoneNode, bone int, deform *AttachmentState) []mgl32.Vec2 {
+	vertices, weightVertices := a.Vertices, a.WeightVertices
+	if deform != nil {
+		vertices, weightVertices = deform.CurrVertices, deform.CurrWeightVertices
+	}
+	res := make([]mgl32.Vec2, 0)
+	if a.Weight {
+		for _, items := range weightVertices {
+			temp := mgl32.Vec2{}
+			for _, item := range items {
+				bone := bones[item.Bone]
+				temp = temp.Add(bone.Mat2.Mul2x1(item.Offset).Add(bone.WorldPos).Mul(item.Weight))
+			}
+			res = append(res, temp)
+		}
+	} else {
+		for _, vertex := range vertices {
+			res = append(res, bones[bone].Mat2.Mul2x1(vertex).Add(bones[bone].WorldPos))
+		}
+	}
+	return res
+}
+
+// 参考 spine-libgdx 3.8 PointAttachment，骨骼需要先完成更新，结果为屏幕坐标
+func (a *Attachment) ComputeWorldPosition(bone *BoneNode) mgl32.Vec2 {
+	return bone.Mat2.Mul2x1(a.Pos).Add(bone.WorldPos)
+}
+
+// 世界旋转角度，屏幕坐标 y 轴向下，顺时针为正
+func (a *Attachment) ComputeWorldRotation(bone *BoneNode) float32 {
+	rad := float64(mgl32.DegToRad(a.Rotate))
+	dir := bone.Mat2.Mul2x1(mgl32.Vec2{float32(math.Cos(rad)), float32(math.Sin(rad))})
+	return Atan2(dir.Y(), dir.X())
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/audio/mp3"
+	"github.com/hajimehoshi/ebiten/v2/audio/vorbis"
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+)
+
+const (
+	AudioSampleRate = 44100
+)
+
+// 播放事件上的音频，作为 EventListener 注册到 AnimController 上
+type AudioPlayer struct {
+	Context *audio.Context
+	FS      fs.FS
+	Dir     string            // 音频目录，事件的音频路径相对于它
+	Sounds  map[string][]byte // 解码后的 32 位浮点双声道数据，加载失败的为 nil 不再重试
+}
+
+// header 中记录了音频目录时使用它，否则音频在 skel 文件所在目录
+func NewAudioPlayer(fsys fs.FS, skelPath string, header *SkelHeader) *AudioPlayer {
+	context := audio.CurrentContext() // 全局只能有一个
+	if context == nil {
+		context = audio.NewContext(AudioSampleRate)
+	}
+	return &AudioPlayer{
+		Context: context,
+		FS:      fsys,
+		Dir:     audioDir(skelPath, header),
+		Sounds:  make(map[string][]byte),
+	}
+}
+
+func (p *AudioPlayer) OnEvent(event *Event) {
+	if len(event.Data.AudioPath) == 0 {
+		return
+	}
+	sound := p.loadSound(event.Data.AudioPath)
+	if sound == nil {
+		return
+	}
+	// 每次触发都新建播放器，允许同一个音频重叠播放
+	player := p.Context.NewPlayerF32FromBytes(ApplyBalance(sound, event.Balance))
+	player.SetVolume(float64(event.Volume))
+	player.Play()
+}
+
+func (p *AudioPlayer) loadSound(path string) []byte {
+	if sound, ok := p.Sounds[path]; ok {
+		return sound
+	}
+	sound, err := p.decodeSound(path)
+	if err != nil { // 缺少音频不影响动画播放
+		fmt.Println("error: load audio", path, err)
+	}
+	p.Sounds[path] = sound
+	return sound
+}
+
+func (p *AudioPlayer) decodeSound(name string) ([]byte, error) {
+	file, err := p.FS.Open(path.Join(p.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var stream interface {
+		io.ReadSeeker
+		Length() int64
+		SampleRate() int
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".wav":
+		stream, err = wav.DecodeF32(file)
+	case ".ogg":
+		stream, err = vorbis.DecodeF32(file)
+	case ".mp3":
+		stream, err = mp3.DecodeF32(file)
+	default:
+		return nil, fmt.Errorf("unsupported audio format: %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 解码器保留原始采样率，需要转换为 Context 的采样率
+	return io.ReadAll(audio.ResampleF32(stream, stream.Length(), stream.SampleRate(), p.Context.SampleRate()))
+}
+
+// 按声道平衡调整左右声道音量，balance -1 只有左声道 1 只有右声道，返回新的数据
+func ApplyBalance(sound []byte, balance float32) []byte {
+	if balance == 0 {
+		return sound
+	}
+	left := 1 - max(balance, 0)
+	right := 1 + min(balance, 0)
+	res := make([]byte, len(sound))
+	for i := 0; i+8 <= len(sound); i += 8 { // 每帧左右声道各一个 float32
+		l := math.Float32frombits(binary.LittleEndian.Uint32(sound[i:]))
+		r := math.Float32frombits(binary.LittleEndian.Uint32(sound[i+4:]))
+		binary.LittleEndian.PutUint32(res[i:], math.Float32bits(l*left))
+		binary.LittleEndian.PutUint32(res[i+4:], math.Float32bits(r*right))
+	}
+	return res
+}
+
+// 头部的音频目录相对于 skel 文件，编辑器所在机器上的绝对路径在 fs.FS 中无法访问，使用 skel 文件所在目录
+func audioDir(skelPath string, header *SkelHeader) string {
+	dir := path.Dir(skelPath)
+	if header == nil || len(header.AudioPath) == 0 {
+		return dir
+	}
+	audioPath := strings.ReplaceAll(header.AudioPath, "\\", "/") // Windows 上保存的路径
+	if path.IsAbs(audioPath) || strings.Contains(audioPath, ":") {
+		return dir
+	}
+	return path.Join(dir, audioPath)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+type BoundingBox struct {
+	Slot       *SlotState
+	Attachment *Attachment
+	Polygon    []mgl32.Vec2 // 世界坐标
+}
+
+// http://zh.esotericsoftware.com/spine-bounding-boxes
+// 参考 spine-libgdx 3.8 SkeletonBounds，每帧骨骼更新后重新计算
+type SkeletonBounds struct {
+	BoundingBoxes []*BoundingBox
+	Min, Max      mgl32.Vec2 // 所有包围盒的 AABB
+}
+
+func (b *SkeletonBounds) Update(skeleton *Skeleton) {
+	b.BoundingBoxes = make([]*BoundingBox, 0)
+	for _, slot := range skeleton.Slots {
+		attachment := skeleton.GetSlotAttachment(slot)
+		if attachment == nil || attachment.Type != AttachmentBoundBox {
+			continue
+		}
+		b.BoundingBoxes = append(b.BoundingBoxes, &BoundingBox{
+			Slot:       slot,
+			Attachment: attachment,
+			Polygon:    skeleton.ComputeWorldVertices(attachment, slot.Slot.Bone),
+		})
+	}
+	b.Min, b.Max = mgl32.Vec2{}, mgl32.Vec2{}
+	if len(b.BoundingBoxes) > 0 {
+		b.Min = mgl32.Vec2{math.MaxFloat32, math.MaxFloat32}
+		b.Max = mgl32.Vec2{-math.MaxFloat32, -math.MaxFloat32}
+	}
+	for _, box := range b.BoundingBoxes {
+		for _, vertex := range box.Polygon {
+			b.Min = mgl32.Vec2{min(b.Min.X(), vertex.X()), min(b.Min.Y(), vertex.Y())}
+			b.Max = mgl32.Vec2{max(b.Max.X(), vertex.X()), max(b.Max.Y(), vertex.Y())}
+		}
+	}
+}
+
+func (b *SkeletonBounds) AabbContainsPoint(point mgl32.Vec2) bool {
+	return point.X() >= b.Min.X() && point.X() <= b.Max.X() && point.Y() >= b.Min.Y() && point.Y() <= b.Max.Y()
+}
+
+func (b *SkeletonBounds) AabbIntersectsSegment(p1, p2 mgl32.Vec2) bool {
+	minX, minY, maxX, maxY := b.Min.X(), b.Min.Y(), b.Max.X(), b.Max.Y()
+	x1, y1, x2, y2 := p1.X(), p1.Y(), p2.X(), p2.Y()
+	if (x1 <= minX && x2 <= minX) || (y1 <= minY && y2 <= minY) || (x1 >= maxX && x2 >= maxX) || (y1 >= maxY && y2 >= maxY) {
+		return false // 线段完全在某一侧
+	}
+	m := (y2 - y1) / (x2 - x1)
+	if y := m*(minX-x1) + y1; y > minY && y < maxY {
+		return true
+	}
+	if y := m*(maxX-x1) + y1; y > minY && y < maxY {
+		return true
+	}
+	if x := (minY-y1)/m + x1; x > minX && x < maxX {
+		return true
+	}
+	if x := (maxY-y1)/m + x1; x > minX && x < maxX {
+		return true
+	}
+	return false
+}
+
+func (b *SkeletonBounds) AabbIntersectsBounds(other *SkeletonBounds) bool {
+	return b.Min.X() < other.Max.X() && b.Max.X() > other.Min.X() && b.Min.Y() < other.Max.Y() && b.Max.Y() > other.Min.Y()
+}
+
+// 返回第一个包含该点的包围盒，没有返回 nil，需要先用 AabbContainsPoint 快速过滤
+func (b *SkeletonBounds) ContainsPoint(point mgl32.Vec2) *BoundingBox {
+	for _, box := range b.BoundingBoxes {
+		if PolygonContainsPoint(box.Polygon, point) {
+			return box
+		}
+	}
+	return nil
+}
+
+// 返回第一个与线段相交的包围盒，没有返回 nil，需要先用 AabbIntersectsSegment 快速过滤
+func (b *SkeletonBounds) IntersectsSegment(p1, p2 mgl32.Vec2) *BoundingBox {
+	for _, box := range b.BoundingBoxes {
+		if PolygonIntersectsSegment(box.Polygon, p1, p2) {
+			return box
+		}
+	}
+	return nil
+}
+
+// 奇偶规则，水平射线与多边形边相交奇数次在内部
+func PolygonContainsPoint(polygon []mgl32.Vec2, point mgl32.Vec2) bool {
+	x, y := point.X(), point.Y()
+	inside := false
+	prev := polygon[len(polygon)-1]
+	for _, vertex := range polygon {
+		if (vertex.Y() < y && prev.Y() >= y) || (prev.Y() < y && vertex.Y() >= y) {
+			if vertex.X()+(y-vertex.Y())/(prev.Y()-vertex.Y())*(prev.X()-vertex.X()) < x {
+				inside = !inside
+			}
+		}
+		prev = vertex
+	}
+	return inside
+}
+
+func PolygonIntersectsSegment(polygon []mgl32.Vec2, p1, p2 mgl32.Vec2) bool {
+	x1, y1, x2, y2 := p1.X(), p1.Y(), p2.X(), p2.Y()
+	width12, height12 := x1-x2, y1-y2
+	det1 := x1*y2 - y1*x2
+	x3, y3 := polygon[len(polygon)-1].X(), polygon[len(polygon)-1].Y()
+	for _, vertex := range polygon { // 依次求线段与每条边所在直线的交点，交点同时在两条线段上就是相交
+		x4, y4 := vertex.X(), vertex.Y()
+		det2 := x3*y4 - y3*x4
+		width34, height34 := x3-x4, y3-y4
+		det3 := width12*height34 - height12*width34
+		x := (det1*width34 - width12*det2) / det3
+		if ((x >= x3 && x <= x4) || (x >= x4 && x <= x3)) && ((x >= x1 && x <= x2) || (x >= x2 && x <= x1)) {
+			y := (det1*height34 - height12*det2) / det3
+			if ((y >= y3 && y <= y4) || (y >= y4 && y <= y3)) && ((y >= y1 && y <= y2) || (y >= y2 && y <= y1)) {
+				return true
+			}
+		}
+		x3, y3 = x4, y4
+	}
+	return false
+}
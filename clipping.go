@@ -0,0 +1,134 @@
+package main
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+// http://zh.esotericsoftware.com/spine-clipping
+// 参考 spine-libgdx 3.8 SkeletonClipping，裁剪附件所在插槽到 EndSlot 之间的插槽都要裁剪
+type SkeletonClipping struct {
+	Attachment *Attachment    // 当前生效的裁剪附件，nil 表示没有裁剪
+	Polygons   [][]mgl32.Vec2 // 裁剪区域分解后的凸多边形，统一方向且首尾点相同
+}
+
+// 开始裁剪，polygon 为裁剪附件的世界坐标，已有裁剪时忽略（不支持嵌套）
+func (c *SkeletonClipping) ClipStart(attachment *Attachment, polygon []mgl32.Vec2) {
+	if c.Attachment != nil || len(polygon) < 3 {
+		return
+	}
+	c.Attachment = attachment
+	polygon = append([]mgl32.Vec2{}, polygon...)
+	makeClockwise(polygon)
+	c.Polygons = Decompose(polygon, Triangulate(polygon))
+	for i, item := range c.Polygons {
+		makeClockwise(item)
+		c.Polygons[i] = append(item, item[0])
+	}
+}
+
+// 每个插槽绘制后调用，到达裁剪附件的 EndSlot 时结束裁剪，slot 为 nil 时直接结束
+func (c *SkeletonClipping) ClipEnd(slot *Slot) {
+	if c.Attachment == nil || (slot != nil && c.Attachment.EndSlot != slot.Index) {
+		return
+	}
+	c.Attachment = nil
+	c.Polygons = nil
+}
+
+func (c *SkeletonClipping) IsClipping() bool {
+	return c.Attachment != nil
+}
+
+// 裁剪三角形，裁剪产生的新顶点按重心坐标插值 UV，完全在裁剪区域内的三角形保持不变
+func (c *SkeletonClipping) ClipTriangles(vertices, uvs []mgl32.Vec2, indices []uint16) ([]mgl32.Vec2, []mgl32.Vec2, []uint16) {
+	resVertices := make([]mgl32.Vec2, 0)
+	resUVs := make([]mgl32.Vec2, 0)
+	resIndices := make([]uint16, 0)
+	for i := 0; i+2 < len(indices); i += 3 {
+		p1, p2, p3 := vertices[indices[i]], vertices[indices[i+1]], vertices[indices[i+2]]
+		uv1, uv2, uv3 := uvs[indices[i]], uvs[indices[i+1]], uvs[indices[i+2]]
+		for _, polygon := range c.Polygons {
+			output, clipped := clipTriangle(p1, p2, p3, polygon)
+			if !clipped { // 完全在内部，其他凸多边形不会再覆盖它
+				index := uint16(len(resVertices))
+				resVertices = append(resVertices, p1, p2, p3)
+				resUVs = append(resUVs, uv1, uv2, uv3)
+				resIndices = append(resIndices, index, index+1, index+2)
+				break
+			}
+			if len(output) < 3 {
+				continue
+			}
+			d0, d1, d2, d4 := p2.Y()-p3.Y(), p3.X()-p2.X(), p1.X()-p3.X(), p3.Y()-p1.Y()
+			d := 1 / (d0*d2 + d1*(p1.Y()-p3.Y()))
+			index := uint16(len(resVertices))
+			for _, point := range output {
+				c0, c1 := point.X()-p3.X(), point.Y()-p3.Y()
+				a := (d0*c0 + d1*c1) * d
+				b := (d4*c0 + d2*c1) * d
+				resVertices = append(resVertices, point)
+				resUVs = append(resUVs, uv1.Mul(a).Add(uv2.Mul(b)).Add(uv3.Mul(1-a-b)))
+			}
+			for j := 1; j+1 < len(output); j++ { // 凸多边形按扇形拆分
+				resIndices = append(resIndices, index, index+uint16(j), index+uint16(j+1))
+			}
+		}
+	}
+	return resVertices, resUVs, resIndices
+}
+
+// Sutherland–Hodgman 算法，clippingArea 为凸多边形且首尾点相同
+// 三角形完全在内部返回 false，否则返回裁剪后的多边形（可能为空）
+func clipTriangle(p1, p2, p3 mgl32.Vec2, clippingArea []mgl32.Vec2) ([]mgl32.Vec2, bool) {
+	input := []mgl32.Vec2{p1, p2, p3, p1}
+	clipped := false
+	for i := 0; i+1 < len(clippingArea); i++ {
+		edge, edge2 := clippingArea[i], clippingArea[i+1]
+		delta := edge.Sub(edge2)
+		output := make([]mgl32.Vec2, 0, len(input)+1)
+		for j := 0; j+1 < len(input); j++ {
+			curr, next := input[j], input[j+1]
+			side := delta.X()*(curr.Y()-edge2.Y())-delta.Y()*(curr.X()-edge2.X()) > 0
+			side2 := delta.X()*(next.Y()-edge2.Y())-delta.Y()*(next.X()-edge2.X()) > 0
+			if side && side2 { // 都在内侧
+				output = append(output, next)
+				continue
+			}
+			if side || side2 { // 与边相交，取交点
+				c0, c2 := next.Y()-curr.Y(), next.X()-curr.X()
+				s := c0*(edge2.X()-edge.X()) - c2*(edge2.Y()-edge.Y())
+				if math.Abs(float64(s)) > 0.000001 {
+					ua := (c2*(edge.Y()-curr.Y()) - c0*(edge.X()-curr.X())) / s
+					output = append(output, edge.Add(edge2.Sub(edge).Mul(ua)))
+				} else {
+					output = append(output, edge)
+				}
+				if side2 {
+					output = append(output, next)
+				}
+			}
+			clipped = true
+		}
+		if len(output) == 0 { // 完全在外侧
+			return nil, true
+		}
+		input = append(output, output[0])
+	}
+	return input[:len(input)-1], clipped
+}
+
+// 统一多边形方向，与 clipTriangle 的内侧判断对应
+func makeClockwise(polygon []mgl32.Vec2) {
+	area := float32(0)
+	for i, curr := range polygon {
+		next := polygon[(i+1)%len(polygon)]
+		area += curr.X()*next.Y() - next.X()*curr.Y()
+	}
+	if area < 0 {
+		return
+	}
+	for i, j := 0, len(polygon)-1; i < j; i, j = i+1, j-1 {
+		polygon[i], polygon[j] = polygon[j], polygon[i]
+	}
+}
@@ -2,10 +2,6 @@ package main
 
 import "github.com/go-gl/mathgl/mgl32"
 
-const (
-	BasePath = "/Users/wepie/Documents/go/my_spine/"
-)
-
 const (
 	GSignX = 1
 	GSignY = -1
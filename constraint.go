@@ -3,135 +3,862 @@ package main
 import (
 	"github.com/go-gl/mathgl/mgl32"
 	"math"
+	"slices"
 )
 
 type ConstraintController struct {
-	Bones                []*Bone
-	PathConstraints      []*PathConstraint
-	TransformConstraints []*TransformConstraint
+	Nodes                []*BoneNode
+	IkConstraints        []*IkConstraintState
+	PathConstraints      []*PathConstraintState
+	TransformConstraints []*TransformConstraintState
+	// 骨骼与约束按依赖关系排好的更新顺序  *BoneNode *IkConstraintState *PathConstraintState *TransformConstraintState
+	UpdateCache []any
 }
 
+// 按 UpdateCache 顺序计算骨骼世界数据并应用约束，约束的骨骼会在约束后再更新子骨骼
 func (c *ConstraintController) Update() {
-	// 实际 ik constraint , path constraint , transform constraint 都是有 order 指定顺序的
-	// 不过一般是  ik -> path -> transform
-	c.updatePathConstraints()
-	c.updateTransformConstraints()
+	for _, item := range c.UpdateCache {
+		switch item := item.(type) {
+		case *BoneNode:
+			item.updateTransform()
+			item.Modify = false
+		case *IkConstraintState:
+			c.updateIkConstraint(item)
+		case *PathConstraintState:
+			c.updatePathConstraint(item)
+		case *TransformConstraintState:
+			c.updateTransformConstraint(item)
+		}
+	}
 }
 
-func (c *ConstraintController) updateTransformConstraints() {
-	for _, item := range c.TransformConstraints {
-		if item.CurrRotateMix <= 0 && item.CurrOffsetMix <= 0 && item.CurrScaleMix <= 0 {
-			continue // 无效值
-		}
-		if item.Local || item.Relative {
-			panic("not implemented")
+// 参考 spine-libgdx 3.8 Skeleton.updateCache，SkinRequire 的骨骼与约束只有皮肤包含时才生效，骨骼生效时其父骨骼也要生效
+// skin 为 nil 时只有非 SkinRequire 的生效
+func (c *ConstraintController) SetSkin(skin *Skin) {
+	if skin == nil {
+		skin = &Skin{}
+	}
+	for _, node := range c.Nodes {
+		node.Active = !node.Bone.SkinRequire
+	}
+	for _, idx := range skin.Bones {
+		for node := c.Nodes[idx]; node != nil; node = node.Parent {
+			node.Active = true
 		}
-		target := c.Bones[item.Target]
-		rotate := GetRotate(target.Mat2) + item.Rotate
-		pos := target.Mat2.Mul2x1(item.Offset).Add(target.WorldPos)
-		scale := GetScale(target.Mat2).Add(item.Scale)
-		for _, idx := range item.Bones {
-			bone := c.Bones[idx]
-			if item.CurrRotateMix > 0 {
-				oldRotate := GetRotate(bone.Mat2)
-				temp := LerpRotate(oldRotate, rotate, item.CurrRotateMix) - oldRotate
-				bone.Mat2 = Rotate(temp).Mul2(bone.Mat2)
-				bone.Modify = true
+	}
+	for i, item := range c.IkConstraints {
+		data := item.IkConstraint
+		item.Active = c.Nodes[data.Target].Active && (!data.SkinRequire || slices.Contains(skin.IkConstraints, i))
+	}
+	for i, item := range c.TransformConstraints {
+		data := item.TransformConstraint
+		item.Active = c.Nodes[data.Target].Active && (!data.SkinRequire || slices.Contains(skin.TransformConstraints, i))
+	}
+	for i, item := range c.PathConstraints { // 当前皮肤下插槽没有路径附件时也不生效
+		data := item.PathConstraint
+		item.Active = item.Attachment != nil && c.Nodes[item.Bone].Active && (!data.SkinRequire || slices.Contains(skin.PathConstraints, i))
+	}
+	c.buildUpdateCache()
+}
+
+// 参考 spine-libgdx 3.8 Skeleton.updateCache
+// 约束按 Order 排序，约束前先加入其依赖的骨骼，约束后重置被约束骨骼的子骨骼，保证子骨骼在约束后更新
+func (c *ConstraintController) buildUpdateCache() {
+	builder := &updateCacheBuilder{Nodes: c.Nodes, Sorted: make([]bool, len(c.Nodes))}
+	for i, node := range c.Nodes { // 未生效的骨骼当作已经排序，不会加入
+		builder.Sorted[i] = !node.Active
+	}
+	count := len(c.IkConstraints) + len(c.TransformConstraints) + len(c.PathConstraints)
+	for i := 0; i < count; i++ {
+		for _, item := range c.IkConstraints {
+			if item.IkConstraint.Order == i {
+				builder.sortIkConstraint(item)
 			}
-			if item.CurrOffsetMix > 0 {
-				bone.WorldPos = Vec2Lerp(bone.WorldPos, pos, item.CurrOffsetMix)
-				bone.Modify = true
+		}
+		for _, item := range c.TransformConstraints {
+			if item.TransformConstraint.Order == i {
+				builder.sortTransformConstraint(item)
 			}
-			if item.CurrScaleMix > 0 {
-				oldScale := GetScale(bone.Mat2) // oldScale 为 0  时会有除0风险
-				temp := Vec2Div(Vec2Lerp(oldScale, scale, item.CurrScaleMix), oldScale)
-				bone.Mat2 = Scale(temp).Mul2(bone.Mat2)
-				bone.Modify = true
+		}
+		for _, item := range c.PathConstraints {
+			if item.PathConstraint.Order == i {
+				builder.sortPathConstraint(item)
 			}
 		}
 	}
+	for _, node := range c.Nodes {
+		builder.sortBone(node)
+	}
+	c.UpdateCache = builder.Cache
 }
 
-func (c *ConstraintController) updatePathConstraints() {
-	for _, item := range c.PathConstraints {
-		if item.CurrOffsetMix <= 0 && item.CurrRotateMix <= 0 {
-			continue // 无效值
-		}
-		if item.SpaceMode == SpaceLength { // rotate 模型只支持最简单的，不做管理
-			//panic("not implemented") // 只实现了部分，也可以直接跳过没有实现的
+type updateCacheBuilder struct {
+	Nodes  []*BoneNode
+	Sorted []bool
+	Cache  []any
+}
+
+func (b *updateCacheBuilder) sortBone(node *BoneNode) {
+	if b.Sorted[node.Index] {
+		return
+	}
+	if node.Parent != nil {
+		b.sortBone(node.Parent)
+	}
+	b.Cache = append(b.Cache, node)
+	b.Sorted[node.Index] = true
+}
+
+// 已经排序的子骨骼需要在约束后重新更新
+func (b *updateCacheBuilder) sortReset(nodes []*BoneNode) {
+	for _, node := range nodes {
+		if !node.Active {
 			continue
 		}
-		attachment := item.Attachment
-		points := make([]mgl32.Vec2, 0) // 只存储线段点，暂时不管控制点
-		if attachment.Weight {          // 2 5 8 ... 3个点一组，前两个是控制点
-			for i := 2; i < len(attachment.CurrWeightVertices); i += 3 {
-				res := mgl32.Vec2{}
-				for _, vec := range attachment.CurrWeightVertices[i] {
-					bone := c.Bones[vec.Bone]
-					temp := bone.Mat2.Mul2x1(vec.Offset).Add(bone.WorldPos)
-					res = res.Add(temp.Mul(vec.Weight))
-				}
-				points = append(points, res)
+		if b.Sorted[node.Index] {
+			b.sortReset(node.Children)
+		}
+		b.Sorted[node.Index] = false
+	}
+}
+
+func (b *updateCacheBuilder) sortIkConstraint(item *IkConstraintState) {
+	if !item.Active {
+		return
+	}
+	data := item.IkConstraint
+	b.sortBone(b.Nodes[data.Target])
+	parent := b.Nodes[data.Bones[0]]
+	b.sortBone(parent)
+	b.Cache = append(b.Cache, item)
+	b.sortReset(parent.Children)
+	b.Sorted[data.Bones[len(data.Bones)-1]] = true // 子骨骼由 IK 计算
+}
+
+func (b *updateCacheBuilder) sortPathConstraint(item *PathConstraintState) {
+	if !item.Active {
+		return
+	}
+	data := item.PathConstraint
+	if item.Attachment.Weight { // 路径依赖的骨骼
+		for _, items := range item.Attachment.WeightVertices {
+			for _, vec := range items {
+				b.sortBone(b.Nodes[vec.Bone])
+			}
+		}
+	} else {
+		b.sortBone(b.Nodes[item.Bone])
+	}
+	for _, idx := range data.Bones {
+		b.sortBone(b.Nodes[idx])
+	}
+	b.Cache = append(b.Cache, item)
+	for _, idx := range data.Bones {
+		b.sortReset(b.Nodes[idx].Children)
+	}
+	for _, idx := range data.Bones {
+		b.Sorted[idx] = true
+	}
+}
+
+func (b *updateCacheBuilder) sortTransformConstraint(item *TransformConstraintState) {
+	if !item.Active {
+		return
+	}
+	data := item.TransformConstraint
+	b.sortBone(b.Nodes[data.Target])
+	for _, idx := range data.Bones {
+		if data.Local { // 局部模式由约束计算骨骼的世界数据，只需要父骨骼先更新
+			if parent := b.Nodes[idx].Parent; parent != nil {
+				b.sortBone(parent)
 			}
 		} else {
-			bone := c.Bones[item.Bone]
-			for i := 2; i < len(attachment.CurrVertices); i += 3 {
-				vec := bone.Mat2.Mul2x1(attachment.CurrVertices[i]).Add(bone.WorldPos)
-				points = append(points, vec)
+			b.sortBone(b.Nodes[idx])
+		}
+	}
+	b.Cache = append(b.Cache, item)
+	for _, idx := range data.Bones {
+		b.sortReset(b.Nodes[idx].Children)
+	}
+	for _, idx := range data.Bones {
+		b.Sorted[idx] = true
+	}
+}
+
+// 参考 spine-libgdx 3.8 IkConstraint 实现，IK 修改的是局部数据，修改后重新计算骨骼的世界数据
+func (c *ConstraintController) updateIkConstraint(item *IkConstraintState) {
+	data := item.IkConstraint
+	if item.CurrMix <= 0 { // 不修改骨骼，但双骨骼的子骨骼不在更新顺序中，仍然要计算世界数据，参考 spine-libgdx 3.8 IkConstraint.apply
+		for _, idx := range data.Bones {
+			if node := c.Nodes[idx]; !node.Modify { // 被其他约束修改过的保留修改后的世界数据
+				node.updateTransform()
 			}
-		} // 计算每段长度与总长度
-		lens := make([]float32, 0)
-		allLen := float32(0)
-		for i := 1; i < len(points); i++ {
-			temp := points[i].Sub(points[i-1]).Len()
-			lens = append(lens, temp)
-			allLen += temp
 		}
-		curr := item.CurrPosition               // 初始化初始值
-		if item.PositionMode == PositionFixed { // fix 转换为 分数
-			curr = item.CurrPosition / attachment.Lengths[len(attachment.Lengths)-1]
+		return
+	}
+	target := c.Nodes[data.Target].WorldPos
+	switch len(data.Bones) {
+	case 1:
+		c.applyIk1(c.Nodes[data.Bones[0]], target, item.CurrCompress, item.CurrStretch, data.Uniform, item.CurrMix)
+	case 2:
+		c.applyIk2(c.Nodes[data.Bones[0]], c.Nodes[data.Bones[1]], target,
+			item.CurrBendDirection, item.CurrStretch, item.CurrSoftness, item.CurrMix)
+	}
+}
+
+// 单骨骼 IK 旋转骨骼朝向目标，可选 拉伸/压缩 骨骼长度
+func (c *ConstraintController) applyIk1(node *BoneNode, target mgl32.Vec2, compress, stretch, uniform bool, alpha float32) {
+	if node.Modify { // 被其他约束修改过，局部数据已失效
+		node.updateAppliedTransform()
+	}
+	pMat, pPos := node.getParentWorld()
+	pa, pb, pc, pd := pMat.At(0, 0), pMat.At(0, 1), pMat.At(1, 0), pMat.At(1, 1)
+	rotateIk := -node.LocalShear.X() - node.LocalRotate
+	var tx, ty float32
+	switch node.Bone.TransformMode {
+	case TransformOnlyTranslation: // 不受父节点旋转影响，只需要移除全局缩放
+		temp := GScaleMat.Inv().Mul2x1(target.Sub(node.WorldPos))
+		tx, ty = temp.X(), temp.Y()
+	case TransformNoRotationOrReflection: // 与 updateTransform 一致，先移除全局缩放再求父节点的旋转
+		ps := mgl32.Abs(pa*pd-pb*pc) / (pa*pa + pc*pc)
+		sa, sc := pa/(GSignX*GScale), pc/(GSignY*GScale)
+		pb = -sc * ps * GSignX * GScale
+		pd = sa * ps * GSignY * GScale
+		rotateIk += Atan2(sc, sa)
+		fallthrough
+	default: // 目标转换到父坐标系下
+		x, y := target.X()-pPos.X(), target.Y()-pPos.Y()
+		d := pa*pd - pb*pc
+		tx = (x*pd-y*pb)/d - node.LocalPos.X()
+		ty = (y*pa-x*pc)/d - node.LocalPos.Y()
+	}
+	rotateIk += Atan2(ty, tx)
+	if node.LocalScale.X() < 0 {
+		rotateIk += 180
+	}
+	rotateIk = AdjustRotate(rotateIk)
+	sx, sy := node.LocalScale.X(), node.LocalScale.Y()
+	if compress || stretch {
+		switch node.Bone.TransformMode {
+		case TransformNoScale, TransformNoScaleOrReflection:
+			temp := GScaleMat.Inv().Mul2x1(target.Sub(node.WorldPos))
+			tx, ty = temp.X(), temp.Y()
+		}
+		b := node.Bone.Length * sx
+		dd := float32(math.Sqrt(float64(tx*tx + ty*ty)))
+		if (compress && dd < b) || (stretch && dd > b) && b > 0.0001 {
+			s := (dd/b-1)*alpha + 1
+			sx *= s
+			if uniform {
+				sy *= s
+			}
 		}
-		space := item.CurrSpace
-		if item.SpaceMode == SpaceFixed { // fix 转换为 分数
-			space = item.CurrSpace / attachment.Lengths[len(attachment.Lengths)-1]
+	}
+	node.LocalRotate += rotateIk * alpha
+	node.LocalScale = mgl32.Vec2{sx, sy}
+	node.updateTransform()
+}
+
+// 双骨骼 IK 父骨骼与子骨骼弯曲到达目标，bendDir 决定弯曲方向
+func (c *ConstraintController) applyIk2(parentNode, childNode *BoneNode, target mgl32.Vec2, bendDir int, stretch bool, softness, alpha float32) {
+	if parentNode.Modify { // 被其他约束修改过，局部数据已失效
+		parentNode.updateAppliedTransform()
+	}
+	if childNode.Modify {
+		childNode.updateAppliedTransform()
+	}
+	parent, child := parentNode, childNode
+	px, py := parent.LocalPos.X(), parent.LocalPos.Y()
+	psx, psy := parent.LocalScale.X(), parent.LocalScale.Y()
+	sx, csx := psx, child.LocalScale.X()
+	var os1, os2, s2 float32 = 0, 0, 1
+	if psx < 0 { // 负缩放转换为 180 度旋转
+		psx = -psx
+		os1 = 180
+		s2 = -1
+	}
+	if psy < 0 {
+		psy = -psy
+		s2 = -s2
+	}
+	if csx < 0 {
+		csx = -csx
+		os2 = 180
+	}
+	a, b, c0, d := parent.Mat2.At(0, 0), parent.Mat2.At(0, 1), parent.Mat2.At(1, 0), parent.Mat2.At(1, 1)
+	uniform := mgl32.Abs(psx-psy) <= 0.0001
+	cx, cy := child.LocalPos.X(), float32(0)
+	var cwx, cwy float32 // 子骨骼的世界坐标
+	if !uniform {        // 非等比缩放时忽略子骨骼的 y 偏移
+		cwx = a*cx + parent.WorldPos.X()
+		cwy = c0*cx + parent.WorldPos.Y()
+	} else {
+		cy = child.LocalPos.Y()
+		cwx = a*cx + b*cy + parent.WorldPos.X()
+		cwy = c0*cx + d*cy + parent.WorldPos.Y()
+	}
+	ppMat, ppPos := parentNode.getParentWorld()
+	a, b, c0, d = ppMat.At(0, 0), ppMat.At(0, 1), ppMat.At(1, 0), ppMat.At(1, 1)
+	id := 1 / (a*d - b*c0)
+	x, y := cwx-ppPos.X(), cwy-ppPos.Y()
+	dx, dy := (x*d-y*b)*id-px, (y*a-x*c0)*id-py
+	l1, l2 := float32(math.Sqrt(float64(dx*dx+dy*dy))), child.Bone.Length*csx
+	if l1 < 0.0001 { // 子骨骼与父骨骼重合，退化为单骨骼
+		c.applyIk1(parentNode, target, false, stretch, false, alpha)
+		child.LocalPos = mgl32.Vec2{cx, cy}
+		child.LocalRotate = 0
+		childNode.updateTransform()
+		return
+	}
+	x, y = target.X()-ppPos.X(), target.Y()-ppPos.Y()
+	tx, ty := (x*d-y*b)*id-px, (y*a-x*c0)*id-py
+	dd := tx*tx + ty*ty
+	if softness != 0 { // 接近伸直时减速
+		softness *= psx * (csx + 1) / 2
+		td := float32(math.Sqrt(float64(dd)))
+		sd := td - l1 - l2*psx + softness
+		if sd > 0 {
+			p := min(1, sd/(softness*2)) - 1
+			p = (sd - softness*(1-p*p)) / td
+			tx -= p * tx
+			ty -= p * ty
+			dd = tx*tx + ty*ty
 		}
-		for _, idx := range item.Bones {
-			bone := c.Bones[idx] // 计算线段位置处的坐标与旋转角度
-			pos, rotate := c.calculatePosAndRotate(points, lens, curr*allLen)
-			rotate += item.Rotate
-			if item.CurrRotateMix > 0 {
-				oldRotate := GetRotate(bone.Mat2)
-				rotate = LerpRotate(oldRotate, rotate, item.CurrRotateMix) - oldRotate
-				bone.Mat2 = Rotate(rotate).Mul2(bone.Mat2)
-				bone.Modify = true
+	}
+	var a1, a2 float32 // 弧度
+	if uniform {
+		l2 *= psx
+		cos := (dd - l1*l1 - l2*l2) / (2 * l1 * l2)
+		if cos < -1 {
+			cos = -1
+		} else if cos > 1 {
+			cos = 1
+			if stretch {
+				sx *= (float32(math.Sqrt(float64(dd)))/(l1+l2)-1)*alpha + 1
 			}
-			if item.CurrOffsetMix > 0 {
-				bone.WorldPos = Vec2Lerp(bone.WorldPos, pos, item.CurrOffsetMix)
-				bone.Modify = true
+		}
+		a2 = float32(math.Acos(float64(cos))) * float32(bendDir)
+		a = l1 + l2*cos
+		b = l2 * float32(math.Sin(float64(a2)))
+		a1 = float32(math.Atan2(float64(ty*a-tx*b), float64(tx*a+ty*b)))
+	} else {
+		a1, a2 = solveIkNonUniform(l1, psx*l2, psy*l2, tx, ty, dd, psx, psy, float32(bendDir))
+	}
+	os := float32(math.Atan2(float64(cy), float64(cx))) * s2
+	rotate := parent.LocalRotate
+	a1 = AdjustRotate(mgl32.RadToDeg(a1-os) + os1 - rotate)
+	parent.LocalRotate = rotate + a1*alpha
+	parent.LocalScale = mgl32.Vec2{sx, parent.LocalScale.Y()}
+	parent.LocalShear = mgl32.Vec2{}
+	parentNode.updateTransform()
+	rotate = child.LocalRotate
+	a2 = AdjustRotate((mgl32.RadToDeg(a2+os)-child.LocalShear.X())*s2 + os2 - rotate)
+	child.LocalPos = mgl32.Vec2{cx, cy}
+	child.LocalRotate = rotate + a2*alpha
+	childNode.updateTransform()
+}
+
+// 父骨骼非等比缩放时子骨骼末端的轨迹是椭圆，求椭圆上离目标最近的解
+func solveIkNonUniform(l1, a, b, tx, ty, dd, psx, psy, bendDir float32) (float32, float32) {
+	aa, bb := a*a, b*b
+	ta := float32(math.Atan2(float64(ty), float64(tx)))
+	c := bb*l1*l1 + aa*dd - aa*bb
+	c1, c2 := -2*bb*l1, bb-aa
+	d := c1*c1 - 4*c2*c
+	if d >= 0 {
+		q := float32(math.Sqrt(float64(d)))
+		if c1 < 0 {
+			q = -q
+		}
+		q = -(c1 + q) / 2
+		r0, r1 := q/c2, c/q
+		r := r1
+		if mgl32.Abs(r0) < mgl32.Abs(r1) {
+			r = r0
+		}
+		if r*r <= dd {
+			y := float32(math.Sqrt(float64(dd-r*r))) * bendDir
+			a1 := ta - float32(math.Atan2(float64(y), float64(r)))
+			a2 := float32(math.Atan2(float64(y/psy), float64((r-l1)/psx)))
+			return a1, a2
+		}
+	} // 无解时取最近或最远的点
+	minAngle, minX, minY := float32(math.Pi), l1-a, float32(0)
+	maxAngle, maxX, maxY := float32(0), l1+a, float32(0)
+	minDist, maxDist := minX*minX, maxX*maxX
+	c = -a * l1 / (aa - bb)
+	if c >= -1 && c <= 1 {
+		c = float32(math.Acos(float64(c)))
+		x := a*float32(math.Cos(float64(c))) + l1
+		y := b * float32(math.Sin(float64(c)))
+		d = x*x + y*y
+		if d < minDist {
+			minAngle, minDist, minX, minY = c, d, x, y
+		}
+		if d > maxDist {
+			maxAngle, maxDist, maxX, maxY = c, d, x, y
+		}
+	}
+	if dd <= (minDist+maxDist)/2 {
+		return ta - float32(math.Atan2(float64(minY*bendDir), float64(minX))), minAngle * bendDir
+	}
+	return ta - float32(math.Atan2(float64(maxY*bendDir), float64(maxX))), maxAngle * bendDir
+}
+
+// 参考 spine-libgdx 3.8 TransformConstraint 实现
+// 世界模式直接修改世界矩阵，局部模式修改局部数据后重新计算世界数据
+// 绝对模式向 Target 靠拢，相对模式叠加 Target 的变换
+func (c *ConstraintController) updateTransformConstraint(item *TransformConstraintState) {
+	data := item.TransformConstraint
+	if data.Local { // 局部模式的骨骼只由约束计算世界数据，混合值都为 0 时也要执行
+		if data.Relative {
+			c.applyRelativeLocal(item)
+		} else {
+			c.applyAbsoluteLocal(item)
+		}
+	} else {
+		if item.CurrRotateMix <= 0 && item.CurrOffsetMix <= 0 && item.CurrScaleMix <= 0 && item.CurrShearMix <= 0 {
+			return // 无效值
+		}
+		if data.Relative {
+			c.applyRelativeWorld(item)
+		} else {
+			c.applyAbsoluteWorld(item)
+		}
+	}
+}
+
+func (c *ConstraintController) applyAbsoluteWorld(item *TransformConstraintState) {
+	data := item.TransformConstraint
+	target := c.Nodes[data.Target]
+	ta, tb, tc, td := target.Mat2.At(0, 0), target.Mat2.At(0, 1), target.Mat2.At(1, 0), target.Mat2.At(1, 1)
+	reflect := float32(1) // 目标被翻转时偏移角度也要翻转
+	if ta*td-tb*tc <= 0 {
+		reflect = -1
+	}
+	pos := target.Mat2.Mul2x1(data.Offset).Add(target.WorldPos)
+	for _, idx := range data.Bones {
+		bone := c.Nodes[idx]
+		if item.CurrRotateMix > 0 {
+			rotate := AdjustRotate(Atan2(tc, ta) - GetRotate(bone.Mat2) + data.Rotate*reflect)
+			bone.Mat2 = Rotate(rotate * item.CurrRotateMix).Mul2(bone.Mat2)
+			bone.Modify = true
+		}
+		if item.CurrOffsetMix > 0 {
+			bone.WorldPos = Vec2Lerp(bone.WorldPos, pos, item.CurrOffsetMix)
+			bone.Modify = true
+		}
+		if item.CurrScaleMix > 0 { // 分别缩放 x y 轴
+			scale := GetWorldScale(bone.Mat2)
+			targetScale := GetWorldScale(target.Mat2)
+			for i := 0; i < 2; i++ {
+				if scale[i] != 0 {
+					scale[i] = (scale[i] + (targetScale[i]-scale[i]+data.Scale[i])*item.CurrScaleMix) / scale[i]
+				}
 			}
-			curr += space
+			bone.Mat2 = bone.Mat2.Mul2(Scale(scale))
+			bone.Modify = true
+		}
+		if item.CurrShearMix > 0 { // 只调整 y 轴的方向
+			b, d := bone.Mat2.At(0, 1), bone.Mat2.At(1, 1)
+			by := Atan2(d, b)
+			rotate := AdjustRotate(Atan2(td, tb) - Atan2(tc, ta) - (by - GetRotate(bone.Mat2)))
+			setAxisY(&bone.Mat2, by+(rotate+data.ShearY*reflect)*item.CurrShearMix)
+			bone.Modify = true
+		}
+	}
+}
+
+func (c *ConstraintController) applyRelativeWorld(item *TransformConstraintState) {
+	data := item.TransformConstraint
+	target := c.Nodes[data.Target]
+	ta, tb, tc, td := target.Mat2.At(0, 0), target.Mat2.At(0, 1), target.Mat2.At(1, 0), target.Mat2.At(1, 1)
+	reflect := float32(1)
+	if ta*td-tb*tc <= 0 {
+		reflect = -1
+	}
+	offset := target.Mat2.Mul2x1(data.Offset).Add(target.WorldPos)
+	for _, idx := range data.Bones {
+		bone := c.Nodes[idx]
+		if item.CurrRotateMix > 0 {
+			rotate := AdjustRotate(Atan2(tc, ta) + data.Rotate*reflect)
+			bone.Mat2 = Rotate(rotate * item.CurrRotateMix).Mul2(bone.Mat2)
+			bone.Modify = true
+		}
+		if item.CurrOffsetMix > 0 {
+			bone.WorldPos = bone.WorldPos.Add(offset.Mul(item.CurrOffsetMix))
+			bone.Modify = true
+		}
+		if item.CurrScaleMix > 0 {
+			scale := GetWorldScale(target.Mat2).Sub(mgl32.Vec2{1, 1}).Add(data.Scale).Mul(item.CurrScaleMix).Add(mgl32.Vec2{1, 1})
+			bone.Mat2 = bone.Mat2.Mul2(Scale(scale))
+			bone.Modify = true
+		}
+		if item.CurrShearMix > 0 {
+			rotate := AdjustRotate(Atan2(td, tb) - Atan2(tc, ta))
+			b, d := bone.Mat2.At(0, 1), bone.Mat2.At(1, 1)
+			setAxisY(&bone.Mat2, Atan2(d, b)+(rotate-90+data.ShearY*reflect)*item.CurrShearMix)
+			bone.Modify = true
+		}
+	}
+}
+
+func (c *ConstraintController) applyAbsoluteLocal(item *TransformConstraintState) {
+	data := item.TransformConstraint
+	target := c.Nodes[data.Target]
+	if target.Modify {
+		target.updateAppliedTransform()
+	}
+	for _, idx := range data.Bones {
+		node := c.Nodes[idx]
+		if node.Modify {
+			node.updateAppliedTransform()
+		}
+		if item.CurrRotateMix > 0 {
+			rotate := AdjustRotate(target.LocalRotate - node.LocalRotate + data.Rotate)
+			node.LocalRotate += rotate * item.CurrRotateMix
+		}
+		if item.CurrOffsetMix > 0 {
+			node.LocalPos = Vec2Lerp(node.LocalPos, target.LocalPos.Add(data.Offset), item.CurrOffsetMix)
+		}
+		if item.CurrScaleMix > 0 {
+			node.LocalScale = Vec2Lerp(node.LocalScale, target.LocalScale.Add(data.Scale), item.CurrScaleMix)
+		}
+		if item.CurrShearMix > 0 {
+			shear := AdjustRotate(target.LocalShear.Y() - node.LocalShear.Y() + data.ShearY)
+			node.LocalShear[1] += shear * item.CurrShearMix
+		}
+		node.updateTransform()
+	}
+}
+
+func (c *ConstraintController) applyRelativeLocal(item *TransformConstraintState) {
+	data := item.TransformConstraint
+	target := c.Nodes[data.Target]
+	if target.Modify {
+		target.updateAppliedTransform()
+	}
+	for _, idx := range data.Bones {
+		node := c.Nodes[idx]
+		if node.Modify {
+			node.updateAppliedTransform()
+		}
+		if item.CurrRotateMix > 0 {
+			node.LocalRotate += (target.LocalRotate + data.Rotate) * item.CurrRotateMix
+		}
+		if item.CurrOffsetMix > 0 {
+			node.LocalPos = node.LocalPos.Add(target.LocalPos.Add(data.Offset).Mul(item.CurrOffsetMix))
 		}
+		if item.CurrScaleMix > 0 {
+			scale := target.LocalScale.Sub(mgl32.Vec2{1, 1}).Add(data.Scale).Mul(item.CurrScaleMix).Add(mgl32.Vec2{1, 1})
+			node.LocalScale = Vec2Mul(node.LocalScale, scale)
+		}
+		if item.CurrShearMix > 0 {
+			node.LocalShear[1] += (target.LocalShear.Y() + data.ShearY) * item.CurrShearMix
+		}
+		node.updateTransform()
 	}
 }
 
-func GetAngle(src, dst mgl32.Vec2) float32 {
-	return float32(math.Atan2(float64(dst.Y()-src.Y()), float64(dst.X()-src.X())) * 180 / math.Pi)
+// 保持 y 轴长度不变，将 y 轴旋转到 rotate 方向
+func setAxisY(mat2 *mgl32.Mat2, rotate float32) {
+	s := float32(math.Sqrt(float64(mat2.At(0, 1)*mat2.At(0, 1) + mat2.At(1, 1)*mat2.At(1, 1))))
+	rad := float64(mgl32.DegToRad(rotate))
+	mat2.Set(0, 1, float32(math.Cos(rad))*s)
+	mat2.Set(1, 1, float32(math.Sin(rad))*s)
 }
 
-func (c *ConstraintController) calculatePosAndRotate(points []mgl32.Vec2, lens []float32, pos float32) (mgl32.Vec2, float32) {
-	if pos < 0 { // 不在范围内直接截断
-		return points[0], GetAngle(points[0], points[1])
+// 参考 spine-libgdx 3.8 PathConstraint 实现
+// 先计算每个骨骼在路径上的间距，再求出路径上对应位置的坐标与切线方向
+func (c *ConstraintController) updatePathConstraint(item *PathConstraintState) {
+	if item.CurrOffsetMix <= 0 && item.CurrRotateMix <= 0 {
+		return // 无效值
+	}
+	data := item.PathConstraint
+	percentSpace := data.SpaceMode == SpacePercent
+	tangents := data.RotateMode == RotateTangent
+	scale := data.RotateMode == RotateChainScale
+	spaceCount := len(data.Bones) // 非切线模式需要额外计算最后一个骨骼的末端
+	if !tangents {
+		spaceCount++
+	}
+	spaces := make([]float32, spaceCount) // 第一个间距为 0
+	lengths := make([]float32, len(data.Bones))
+	if scale || !percentSpace {
+		for i := 0; i < spaceCount-1; i++ {
+			bone := c.Nodes[data.Bones[i]]
+			setupLength := bone.Bone.Length
+			length := setupLength * bone.Mat2.Col(0).Len() // 骨骼的世界长度
+			if setupLength < 0.00001 {
+				spaces[i+1] = 0
+			} else if percentSpace {
+				lengths[i] = length
+				spaces[i+1] = item.CurrSpace
+			} else {
+				lengths[i] = length
+				if data.SpaceMode == SpaceLength { // 间距为骨骼长度加上 Space
+					spaces[i+1] = (setupLength + item.CurrSpace) * length / setupLength
+				} else {
+					spaces[i+1] = item.CurrSpace * length / setupLength
+				}
+			}
+		}
+	} else {
+		for i := 1; i < spaceCount; i++ {
+			spaces[i] = item.CurrSpace
+		}
 	}
-	for i := 0; i < len(lens); i++ {
-		if pos > lens[i] {
-			pos -= lens[i]
+	positions := c.computePathPositions(item, spaces, tangents, data.PositionMode == PositionPercent, percentSpace)
+	pos := positions[0].Vec2()
+	offsetRotate := data.Rotate
+	tip := false // 链式旋转时让骨骼末端落在路径上
+	if offsetRotate == 0 {
+		tip = data.RotateMode == RotateChain
+	} else if c.Nodes[item.Bone].Mat2.Det() <= 0 { // 路径被翻转时偏移角度也要翻转
+		offsetRotate = -offsetRotate
+	}
+	for i, idx := range data.Bones {
+		bone := c.Nodes[idx]
+		bone.WorldPos = Vec2Lerp(bone.WorldPos, pos, item.CurrOffsetMix)
+		next := positions[i+1].Vec2()
+		offset := next.Sub(pos)
+		if scale && lengths[i] != 0 { // 拉伸骨骼到下一个位置
+			s := (offset.Len()/lengths[i]-1)*item.CurrRotateMix + 1
+			bone.Mat2 = bone.Mat2.Mul2(Scale(mgl32.Vec2{s, 1}))
+		}
+		pos = next
+		if item.CurrRotateMix > 0 {
+			a, c0 := bone.Mat2.At(0, 0), bone.Mat2.At(1, 0)
+			var rotate float32
+			if tangents {
+				rotate = positions[i].Z()
+			} else if spaces[i+1] == 0 {
+				rotate = positions[i+1].Z()
+			} else {
+				rotate = Atan2(offset.Y(), offset.X())
+			}
+			rotate -= Atan2(c0, a)
+			if tip {
+				rad := float64(mgl32.DegToRad(rotate))
+				cos, sin := float32(math.Cos(rad)), float32(math.Sin(rad))
+				pos[0] += (bone.Bone.Length*(cos*a-sin*c0) - offset.X()) * item.CurrRotateMix
+				pos[1] += (bone.Bone.Length*(sin*a+cos*c0) - offset.Y()) * item.CurrRotateMix
+			} else {
+				rotate += offsetRotate
+			}
+			bone.Mat2 = Rotate(AdjustRotate(rotate) * item.CurrRotateMix).Mul2(bone.Mat2)
+		}
+		bone.Modify = true
+	}
+}
+
+// 返回 len(spaces)+1 个位置 x y 为坐标 z 为切线角度，最后一个只是占位
+func (c *ConstraintController) computePathPositions(item *PathConstraintState, spaces []float32, tangents, percentPosition, percentSpace bool) []mgl32.Vec3 {
+	attachment := item.Attachment
+	points := attachment.ComputeWorldVertices(c.Nodes, item.Bone, item.Deform) // 每 3 个点一组 (入控制点 点 出控制点)
+	res := make([]mgl32.Vec3, len(spaces)+1)
+	position := item.CurrPosition
+	curveCount := len(points) / 3
+	if !attachment.ConstantSpeed { // 直接使用预计算的曲线长度，曲线内部按参数插值
+		if attachment.Close {
+			curveCount--
+		} else {
+			curveCount -= 2
+		}
+		pathLength := attachment.Lengths[curveCount]
+		if percentPosition {
+			position *= pathLength
+		}
+		if percentSpace {
+			for i := 1; i < len(spaces); i++ {
+				spaces[i] *= pathLength
+			}
+		}
+		curve := 0
+		for i, space := range spaces {
+			position += space
+			p := position
+			if attachment.Close { // 闭合路径循环
+				p = float32(math.Mod(float64(p), float64(pathLength)))
+				if p < 0 {
+					p += pathLength
+				}
+				curve = 0
+			} else if p < 0 { // 超出路径的部分沿首尾方向延伸
+				res[i] = addBeforePosition(p, points[1], points[2])
+				continue
+			} else if p > pathLength {
+				res[i] = addAfterPosition(p-pathLength, points[len(points)-3], points[len(points)-2])
+				continue
+			}
+			for ; ; curve++ { // 找到所在的曲线
+				length := attachment.Lengths[curve]
+				if p > length {
+					continue
+				}
+				if curve == 0 {
+					p /= length
+				} else {
+					prev := attachment.Lengths[curve-1]
+					p = (p - prev) / (length - prev)
+				}
+				break
+			}
+			var curvePoints [4]mgl32.Vec2
+			if attachment.Close && curve == curveCount { // 首尾相连的曲线
+				curvePoints = [4]mgl32.Vec2{points[len(points)-2], points[len(points)-1], points[0], points[1]}
+			} else {
+				copy(curvePoints[:], points[curve*3+1:curve*3+5])
+			}
+			res[i] = addCurvePosition(p, curvePoints, tangents || (i > 0 && space == 0))
+		}
+		return res
+	}
+	// 匀速模式 需要计算实际的曲线长度  world 为 点 出控制点 入控制点 点 ...
+	world := make([]mgl32.Vec2, 0)
+	if attachment.Close {
+		world = append(world, points[1:]...)
+		world = append(world, points[0], points[1])
+	} else {
+		curveCount--
+		world = append(world, points[1:len(points)-1]...)
+	}
+	curves := make([]float32, curveCount)
+	pathLength := float32(0)
+	for i := 0; i < curveCount; i++ {
+		pathLength += getCurveLength(world[i*3:i*3+4], 4)
+		curves[i] = pathLength
+	}
+	if percentPosition {
+		position *= pathLength
+	} else {
+		position *= pathLength / attachment.Lengths[curveCount-1]
+	}
+	if percentSpace {
+		for i := 1; i < len(spaces); i++ {
+			spaces[i] *= pathLength
+		}
+	}
+	curve, prevCurve := 0, -1
+	var segments []float32
+	for i, space := range spaces {
+		position += space
+		p := position
+		if attachment.Close {
+			p = float32(math.Mod(float64(p), float64(pathLength)))
+			if p < 0 {
+				p += pathLength
+			}
+			curve = 0
+		} else if p < 0 {
+			res[i] = addBeforePosition(p, world[0], world[1])
+			continue
+		} else if p > pathLength {
+			res[i] = addAfterPosition(p-pathLength, world[len(world)-2], world[len(world)-1])
 			continue
 		}
-		return Vec2Lerp(points[i], points[i+1], pos/lens[i]), GetAngle(points[i], points[i+1])
+		for ; ; curve++ {
+			length := curves[curve]
+			if p > length {
+				continue
+			}
+			if curve == 0 {
+				p /= length
+			} else {
+				prev := curves[curve-1]
+				p = (p - prev) / (length - prev)
+			}
+			break
+		}
+		curvePoints := world[curve*3 : curve*3+4]
+		if curve != prevCurve { // 曲线分为 10 段，按每段长度换算参数
+			prevCurve = curve
+			segments = getCurveSegments(curvePoints)
+		}
+		p *= segments[len(segments)-1]
+		segment := 0
+		for ; ; segment++ {
+			length := segments[segment]
+			if p > length {
+				continue
+			}
+			if segment == 0 {
+				p /= length
+			} else {
+				prev := segments[segment-1]
+				p = float32(segment) + (p-prev)/(length-prev)
+			}
+			break
+		}
+		res[i] = addCurvePosition(p*0.1, [4]mgl32.Vec2(curvePoints), tangents || (i > 0 && space == 0))
+	}
+	return res
+}
+
+// 使用前向差分计算贝塞尔曲线长度，分为 count 段
+func getCurveLength(points []mgl32.Vec2, count int) float32 {
+	segments := getForwardDifferences(points, count)
+	return segments[len(segments)-1]
+}
+
+func getCurveSegments(points []mgl32.Vec2) []float32 {
+	return getForwardDifferences(points, 10)
+}
+
+// 返回前 i 段的累计长度
+func getForwardDifferences(points []mgl32.Vec2, count int) []float32 {
+	p1, c1, c2, p2 := points[0], points[1], points[2], points[3]
+	step := 1 / float32(count)
+	step2, step3 := step*step, step*step*step
+	temp := p1.Sub(c1.Mul(2)).Add(c2).Mul(3 * step2)
+	ddd := c1.Sub(c2).Mul(3).Sub(p1).Add(p2).Mul(6 * step3)
+	dd := temp.Mul(2).Add(ddd)
+	d := c1.Sub(p1).Mul(3 * step).Add(temp).Add(ddd.Mul(1.0 / 6))
+	res := make([]float32, count)
+	length := float32(0)
+	for i := 0; i < count; i++ {
+		length += d.Len()
+		res[i] = length
+		d = d.Add(dd)
+		dd = dd.Add(ddd)
+	}
+	return res
+}
+
+func addBeforePosition(p float32, p1, p2 mgl32.Vec2) mgl32.Vec3 {
+	rotate := Atan2(p2.Y()-p1.Y(), p2.X()-p1.X())
+	rad := float64(mgl32.DegToRad(rotate))
+	return mgl32.Vec3{p1.X() + p*float32(math.Cos(rad)), p1.Y() + p*float32(math.Sin(rad)), rotate}
+}
+
+func addAfterPosition(p float32, p1, p2 mgl32.Vec2) mgl32.Vec3 {
+	rotate := Atan2(p2.Y()-p1.Y(), p2.X()-p1.X())
+	rad := float64(mgl32.DegToRad(rotate))
+	return mgl32.Vec3{p2.X() + p*float32(math.Cos(rad)), p2.Y() + p*float32(math.Sin(rad)), rotate}
+}
+
+// 三次贝塞尔曲线上 p 处的坐标，需要时计算切线角度
+func addCurvePosition(p float32, points [4]mgl32.Vec2, tangents bool) mgl32.Vec3 {
+	p1, c1, c2, p2 := points[0], points[1], points[2], points[3]
+	if p < 0.00001 || math.IsNaN(float64(p)) {
+		return mgl32.Vec3{p1.X(), p1.Y(), Atan2(c1.Y()-p1.Y(), c1.X()-p1.X())}
+	}
+	tt, u := p*p, 1-p
+	uu, ut := u*u, u*p
+	pos := p1.Mul(uu * u).Add(c1.Mul(uu * p * 3)).Add(c2.Mul(ut * p * 3)).Add(p2.Mul(tt * p))
+	if !tangents {
+		return pos.Vec3(0)
+	}
+	if p < 0.001 {
+		return pos.Vec3(Atan2(c1.Y()-p1.Y(), c1.X()-p1.X()))
 	}
-	return points[len(points)-1], GetAngle(points[len(points)-2], points[len(points)-1])
+	temp := p1.Mul(uu).Add(c1.Mul(ut * 2)).Add(c2.Mul(tt)) // 二次曲线上的点，与 pos 的连线即切线
+	return pos.Vec3(Atan2(pos.Y()-temp.Y(), pos.X()-temp.X()))
 }
 
-func NewConstraintController(bones []*Bone, pathConstraints []*PathConstraint, transformConstraints []*TransformConstraint) *ConstraintController {
-	return &ConstraintController{Bones: bones, PathConstraints: pathConstraints, TransformConstraints: transformConstraints}
+func NewConstraintController(nodes []*BoneNode, ikConstraints []*IkConstraintState, pathConstraints []*PathConstraintState, transformConstraints []*TransformConstraintState) *ConstraintController {
+	res := &ConstraintController{Nodes: nodes, IkConstraints: ikConstraints,
+		PathConstraints: pathConstraints, TransformConstraints: transformConstraints}
+	res.SetSkin(nil)
+	return res
 }
@@ -0,0 +1,589 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/go-gl/mathgl/mgl32"
+	"io"
+	"math"
+)
+
+// 导出为 spine 3.8 json，可以用 LoadSkelJson 或 spine 编辑器重新导入，与默认值相同的字段省略
+// 二进制解析 twoColor 时暗色的蓝色通道被 alpha 覆盖，导出的为 ff
+func SaveSkelJson(w io.Writer, skel *SkeletonData) error {
+	res := &jsonMap{}
+	res.Set("skeleton", exportHeader(skel.Header))
+	bones := make([]*jsonMap, 0)
+	for _, item := range skel.Bones {
+		bones = append(bones, exportBone(skel, item))
+	}
+	res.Set("bones", bones)
+	slots := make([]*jsonMap, 0)
+	for _, item := range skel.Slots {
+		slots = append(slots, exportSlot(skel, item))
+	}
+	res.Set("slots", slots)
+	if len(skel.IkConstraints) > 0 {
+		items := make([]*jsonMap, 0)
+		for _, item := range skel.IkConstraints {
+			items = append(items, exportIkConstraint(skel, item))
+		}
+		res.Set("ik", items)
+	}
+	if len(skel.TransformConstraints) > 0 {
+		items := make([]*jsonMap, 0)
+		for _, item := range skel.TransformConstraints {
+			items = append(items, exportTransformConstraint(skel, item))
+		}
+		res.Set("transform", items)
+	}
+	if len(skel.PathConstraints) > 0 {
+		items := make([]*jsonMap, 0)
+		for _, item := range skel.PathConstraints {
+			items = append(items, exportPathConstraint(skel, item))
+		}
+		res.Set("path", items)
+	}
+	skins := make([]*jsonMap, 0)
+	for _, item := range skel.Skins {
+		skins = append(skins, exportSkin(skel, item))
+	}
+	res.Set("skins", skins)
+	if len(skel.Events) > 0 {
+		events := &jsonMap{}
+		for _, item := range skel.Events {
+			events.Set(item.Name, exportEventData(item))
+		}
+		res.Set("events", events)
+	}
+	if len(skel.Animations) > 0 {
+		animations := &jsonMap{}
+		for _, item := range skel.Animations {
+			animations.Set(item.Name, exportAnimation(skel, item))
+		}
+		res.Set("animations", animations)
+	}
+	bs, err := json.MarshalIndent(res, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bs)
+	return err
+}
+
+func exportHeader(header *SkelHeader) *jsonMap {
+	res := &jsonMap{}
+	res.Set("hash", header.Hash)
+	res.Set("spine", exportVersion(header.Version))
+	res.Set("x", header.Pos.X())
+	res.Set("y", header.Pos.Y())
+	res.Set("width", header.Size.X())
+	res.Set("height", header.Size.Y())
+	if header.Nonessential {
+		res.Set("fps", header.Fps)
+		res.Set("images", header.ImagesPath)
+		res.Set("audio", header.AudioPath)
+	}
+	return res
+}
+
+func exportBone(skel *SkeletonData, bone *Bone) *jsonMap {
+	res := &jsonMap{}
+	res.Set("name", bone.Name)
+	if bone.Parent >= 0 {
+		res.Set("parent", skel.Bones[bone.Parent].Name)
+	}
+	res.SetDefault("length", bone.Length, float32(0))
+	res.SetDefault("rotation", bone.Rotate, float32(0))
+	res.SetDefault("x", bone.Pos.X(), float32(0))
+	res.SetDefault("y", bone.Pos.Y(), float32(0))
+	res.SetDefault("scaleX", bone.Scale.X(), float32(1))
+	res.SetDefault("scaleY", bone.Scale.Y(), float32(1))
+	res.SetDefault("shearX", bone.Shear.X(), float32(0))
+	res.SetDefault("shearY", bone.Shear.Y(), float32(0))
+	res.SetDefault("transform", enumName(int(bone.TransformMode), "normal", "onlyTranslation", "noRotationOrReflection", "noScale", "noScaleOrReflection"), "normal")
+	res.SetDefault("skin", bone.SkinRequire, false)
+	if bone.Color != (mgl32.Vec4{}) {
+		res.Set("color", formatRgba(bone.Color))
+	}
+	return res
+}
+
+func exportSlot(skel *SkeletonData, slot *Slot) *jsonMap {
+	res := &jsonMap{}
+	res.Set("name", slot.Name)
+	res.Set("bone", skel.Bones[slot.Bone].Name)
+	res.SetDefault("color", formatRgba(slot.Color), "ffffffff")
+	if slot.DarkColor != (mgl32.Vec4{1, 1, 1, 1}) {
+		res.Set("dark", formatRgb(slot.DarkColor))
+	}
+	res.SetDefault("attachment", slot.Attachment, "")
+	res.SetDefault("blend", enumName(int(slot.BlendMode), "normal", "additive", "multiply", "screen"), "normal")
+	return res
+}
+
+func exportIkConstraint(skel *SkeletonData, constraint *IkConstraint) *jsonMap {
+	res := &jsonMap{}
+	res.Set("name", constraint.Name)
+	res.Set("order", constraint.Order)
+	res.SetDefault("skin", constraint.SkinRequire, false)
+	res.Set("bones", boneNames(skel, constraint.Bones))
+	res.Set("target", skel.Bones[constraint.Target].Name)
+	res.SetDefault("mix", constraint.Mix, float32(1))
+	res.SetDefault("softness", constraint.Softness, float32(0))
+	res.SetDefault("bendPositive", constraint.BendDirection > 0, true)
+	res.SetDefault("compress", constraint.Compress, false)
+	res.SetDefault("stretch", constraint.Stretch, false)
+	res.SetDefault("uniform", constraint.Uniform, false)
+	return res
+}
+
+func exportTransformConstraint(skel *SkeletonData, constraint *TransformConstraint) *jsonMap {
+	res := &jsonMap{}
+	res.Set("name", constraint.Name)
+	res.Set("order", constraint.Order)
+	res.SetDefault("skin", constraint.SkinRequire, false)
+	res.Set("bones", boneNames(skel, constraint.Bones))
+	res.Set("target", skel.Bones[constraint.Target].Name)
+	res.SetDefault("local", constraint.Local, false)
+	res.SetDefault("relative", constraint.Relative, false)
+	res.SetDefault("rotation", constraint.Rotate, float32(0))
+	res.SetDefault("x", constraint.Offset.X(), float32(0))
+	res.SetDefault("y", constraint.Offset.Y(), float32(0))
+	res.SetDefault("scaleX", constraint.Scale.X(), float32(0))
+	res.SetDefault("scaleY", constraint.Scale.Y(), float32(0))
+	res.SetDefault("shearY", constraint.ShearY, float32(0))
+	res.SetDefault("rotateMix", constraint.RotateMix, float32(1))
+	res.SetDefault("translateMix", constraint.OffsetMix, float32(1))
+	res.SetDefault("scaleMix", constraint.ScaleMix, float32(1))
+	res.SetDefault("shearMix", constraint.ShearMix, float32(1))
+	return res
+}
+
+func exportPathConstraint(skel *SkeletonData, constraint *PathConstraint) *jsonMap {
+	res := &jsonMap{}
+	res.Set("name", constraint.Name)
+	res.Set("order", constraint.Order)
+	res.SetDefault("skin", constraint.SkinRequire, false)
+	res.Set("bones", boneNames(skel, constraint.Bones))
+	res.Set("target", skel.Slots[constraint.Target].Name)
+	res.SetDefault("positionMode", enumName(int(constraint.PositionMode), "fixed", "percent"), "percent")
+	res.SetDefault("spacingMode", enumName(int(constraint.SpaceMode), "length", "fixed", "percent"), "length")
+	res.SetDefault("rotateMode", enumName(int(constraint.RotateMode), "tangent", "chain", "chainScale"), "tangent")
+	res.SetDefault("rotation", constraint.Rotate, float32(0))
+	res.SetDefault("position", constraint.Position, float32(0))
+	res.SetDefault("spacing", constraint.Space, float32(0))
+	res.SetDefault("rotateMix", constraint.RotateMix, float32(1))
+	res.SetDefault("translateMix", constraint.OffsetMix, float32(1))
+	return res
+}
+
+func exportSkin(skel *SkeletonData, skin *Skin) *jsonMap {
+	res := &jsonMap{}
+	res.Set("name", skin.Name)
+	if len(skin.Bones) > 0 {
+		res.Set("bones", boneNames(skel, skin.Bones))
+	}
+	if len(skin.IkConstraints) > 0 {
+		names := make([]string, 0)
+		for _, item := range skin.IkConstraints {
+			names = append(names, skel.IkConstraints[item].Name)
+		}
+		res.Set("ik", names)
+	}
+	if len(skin.TransformConstraints) > 0 {
+		names := make([]string, 0)
+		for _, item := range skin.TransformConstraints {
+			names = append(names, skel.TransformConstraints[item].Name)
+		}
+		res.Set("transform", names)
+	}
+	if len(skin.PathConstraints) > 0 {
+		names := make([]string, 0)
+		for _, item := range skin.PathConstraints {
+			names = append(names, skel.PathConstraints[item].Name)
+		}
+		res.Set("path", names)
+	}
+	slots := &jsonGroup{}
+	for _, item := range skin.Attachments {
+		slots.Get(skel.Slots[item.Slot].Name).Set(item.Name, exportAttachment(skel, item))
+	}
+	res.Set("attachments", slots.Map())
+	return res
+}
+
+func exportAttachment(skel *SkeletonData, attachment *Attachment) *jsonMap {
+	res := &jsonMap{}
+	res.SetDefault("type", enumName(int(attachment.Type), "region", "boundingbox", "mesh", "linkedmesh", "path", "point", "clipping"), "region")
+	res.SetDefault("name", attachment.RealName, "")
+	name := attachment.RealName
+	if len(name) == 0 {
+		name = attachment.Name
+	}
+	switch attachment.Type {
+	case AttachmentRegion, AttachmentMesh, AttachmentLinkMesh:
+		res.SetDefault("path", attachment.Path, name)
+		res.SetDefault("color", formatRgba(attachment.Color), "ffffffff")
+	default:
+		if attachment.Color != (mgl32.Vec4{}) {
+			res.Set("color", formatRgba(attachment.Color))
+		}
+	}
+	switch attachment.Type {
+	case AttachmentRegion:
+		res.SetDefault("x", attachment.Pos.X(), float32(0))
+		res.SetDefault("y", attachment.Pos.Y(), float32(0))
+		res.SetDefault("scaleX", attachment.Scale.X(), float32(1))
+		res.SetDefault("scaleY", attachment.Scale.Y(), float32(1))
+		res.SetDefault("rotation", attachment.Rotate, float32(0))
+		res.Set("width", attachment.Size.X())
+		res.Set("height", attachment.Size.Y())
+	case AttachmentMesh:
+		uvs := make([]float32, 0)
+		for _, item := range attachment.UVs {
+			uvs = append(uvs, item.X(), item.Y())
+		}
+		res.Set("uvs", uvs)
+		res.Set("triangles", attachment.Indices)
+		res.Set("vertices", exportVertices(attachment))
+		res.Set("hull", attachment.HullLength)
+		if len(attachment.Edges) > 0 {
+			res.Set("edges", attachment.Edges)
+		}
+		exportSize(res, attachment.Size)
+	case AttachmentLinkMesh:
+		res.SetDefault("skin", attachment.ParentSkin, "")
+		res.Set("parent", attachment.ParentName)
+		res.SetDefault("deform", attachment.InheritDeform, true)
+		exportSize(res, attachment.Size)
+	case AttachmentBoundBox:
+		res.Set("vertexCount", vertexCount(attachment))
+		res.Set("vertices", exportVertices(attachment))
+	case AttachmentPath:
+		res.SetDefault("closed", attachment.Close, false)
+		res.SetDefault("constantSpeed", attachment.ConstantSpeed, false)
+		res.Set("vertexCount", vertexCount(attachment))
+		res.Set("vertices", exportVertices(attachment))
+		res.Set("lengths", attachment.Lengths)
+	case AttachmentPoint:
+		res.SetDefault("x", attachment.Pos.X(), float32(0))
+		res.SetDefault("y", attachment.Pos.Y(), float32(0))
+		res.SetDefault("rotation", attachment.Rotate, float32(0))
+	case AttachmentClip:
+		res.Set("end", skel.Slots[attachment.EndSlot].Name)
+		res.Set("vertexCount", vertexCount(attachment))
+		res.Set("vertices", exportVertices(attachment))
+	}
+	return res
+}
+
+// Mesh 的尺寸为非必要数据
+func exportSize(res *jsonMap, size mgl32.Vec2) {
+	if size != (mgl32.Vec2{}) {
+		res.Set("width", size.X())
+		res.Set("height", size.Y())
+	}
+}
+
+func vertexCount(attachment *Attachment) int {
+	if attachment.Weight {
+		return len(attachment.WeightVertices)
+	}
+	return len(attachment.Vertices)
+}
+
+// 与 parseJsonVertices 对应
+func exportVertices(attachment *Attachment) []float32 {
+	res := make([]float32, 0)
+	if !attachment.Weight {
+		for _, item := range attachment.Vertices {
+			res = append(res, item.X(), item.Y())
+		}
+		return res
+	}
+	for _, items := range attachment.WeightVertices {
+		res = append(res, float32(len(items)))
+		for _, item := range items {
+			res = append(res, float32(item.Bone), item.Offset.X(), item.Offset.Y(), item.Weight)
+		}
+	}
+	return res
+}
+
+func exportEventData(event *EventData) *jsonMap {
+	res := &jsonMap{}
+	res.SetDefault("int", event.Int, 0)
+	res.SetDefault("float", event.Float, float32(0))
+	res.SetDefault("string", event.String, "")
+	if len(event.AudioPath) > 0 {
+		res.Set("audio", event.AudioPath)
+		res.SetDefault("volume", event.Volume, float32(1))
+		res.SetDefault("balance", event.Balance, float32(0))
+	}
+	return res
+}
+
+func exportAnimation(skel *SkeletonData, animation *Animation) *jsonMap {
+	slots := &jsonGroup{}
+	bones := &jsonGroup{}
+	ik := &jsonMap{}
+	transform := &jsonMap{}
+	path := &jsonGroup{}
+	deform := make(map[int]*jsonGroup) // 按 skin 分组
+	deformSkins := make([]int, 0)
+	var drawOrder, events []*jsonMap
+	for _, timeline := range animation.Timelines {
+		switch timeline.Type {
+		case TimelineAttachment:
+			slots.Get(skel.Slots[timeline.Slot].Name).Set("attachment", exportKeyFrames(timeline, func(frame *KeyFrame, res *jsonMap) {
+				if len(frame.Attachment) > 0 {
+					res.Set("name", frame.Attachment)
+				} else {
+					res.Set("name", nil)
+				}
+			}))
+		case TimelineColor:
+			slots.Get(skel.Slots[timeline.Slot].Name).Set("color", exportKeyFrames(timeline, func(frame *KeyFrame, res *jsonMap) {
+				res.Set("color", formatRgba(frame.Color))
+			}))
+		case TimelineTwoColor:
+			slots.Get(skel.Slots[timeline.Slot].Name).Set("twoColor", exportKeyFrames(timeline, func(frame *KeyFrame, res *jsonMap) {
+				res.Set("light", formatRgba(frame.Color))
+				res.Set("dark", formatRgb(frame.DarkColor))
+			}))
+		case TimelineRotate:
+			bones.Get(skel.Bones[timeline.Bone].Name).Set("rotate", exportKeyFrames(timeline, func(frame *KeyFrame, res *jsonMap) {
+				res.SetDefault("angle", frame.Rotate, float32(0))
+			}))
+		case TimelineTranslate:
+			bones.Get(skel.Bones[timeline.Bone].Name).Set("translate", exportKeyFrames(timeline, func(frame *KeyFrame, res *jsonMap) {
+				exportXY(res, frame.Offset, 0)
+			}))
+		case TimelineScale:
+			bones.Get(skel.Bones[timeline.Bone].Name).Set("scale", exportKeyFrames(timeline, func(frame *KeyFrame, res *jsonMap) {
+				exportXY(res, frame.Scale, 1)
+			}))
+		case TimelineShear:
+			bones.Get(skel.Bones[timeline.Bone].Name).Set("shear", exportKeyFrames(timeline, func(frame *KeyFrame, res *jsonMap) {
+				exportXY(res, frame.Shear, 0)
+			}))
+		case TimelineIkConstraint:
+			ik.Set(skel.IkConstraints[timeline.IkConstraint].Name, exportKeyFrames(timeline, func(frame *KeyFrame, res *jsonMap) {
+				res.SetDefault("mix", frame.Mix, float32(1))
+				res.SetDefault("softness", frame.Softness, float32(0))
+				res.SetDefault("bendPositive", frame.BendDirection > 0, true)
+				res.SetDefault("compress", frame.Compress, false)
+				res.SetDefault("stretch", frame.Stretch, false)
+			}))
+		case TimelineTransformConstraint:
+			transform.Set(skel.TransformConstraints[timeline.TransformConstraint].Name, exportKeyFrames(timeline, func(frame *KeyFrame, res *jsonMap) {
+				res.SetDefault("rotateMix", frame.RotateMix, float32(1))
+				res.SetDefault("translateMix", frame.OffsetMix, float32(1))
+				res.SetDefault("scaleMix", frame.ScaleMix, float32(1))
+				res.SetDefault("shearMix", frame.ShearMix, float32(1))
+			}))
+		case TimelinePathConstraintPosition:
+			path.Get(skel.PathConstraints[timeline.PathConstraint].Name).Set("position", exportKeyFrames(timeline, func(frame *KeyFrame, res *jsonMap) {
+				res.SetDefault("position", frame.Position, float32(0))
+			}))
+		case TimelinePathConstraintSpace:
+			path.Get(skel.PathConstraints[timeline.PathConstraint].Name).Set("spacing", exportKeyFrames(timeline, func(frame *KeyFrame, res *jsonMap) {
+				res.SetDefault("spacing", frame.Space, float32(0))
+			}))
+		case TimelinePathConstraintMix:
+			path.Get(skel.PathConstraints[timeline.PathConstraint].Name).Set("mix", exportKeyFrames(timeline, func(frame *KeyFrame, res *jsonMap) {
+				res.SetDefault("rotateMix", frame.RotateMix, float32(1))
+				res.SetDefault("translateMix", frame.OffsetMix, float32(1))
+			}))
+		case TimelineDeform:
+			if deform[timeline.Skin] == nil {
+				deform[timeline.Skin] = &jsonGroup{}
+				deformSkins = append(deformSkins, timeline.Skin)
+			}
+			deform[timeline.Skin].Get(skel.Slots[timeline.Slot].Name).Set(timeline.Attachment, exportKeyFrames(timeline, exportDeform))
+		case TimelineDrawOrder:
+			for _, frame := range timeline.KeyFrames {
+				drawOrder = append(drawOrder, exportDrawOrder(skel, frame))
+			}
+		case TimelineEvent:
+			for _, frame := range timeline.KeyFrames {
+				events = append(events, exportEvent(frame))
+			}
+		}
+	}
+	res := &jsonMap{}
+	if len(slots.Keys) > 0 {
+		res.Set("slots", slots.Map())
+	}
+	if len(bones.Keys) > 0 {
+		res.Set("bones", bones.Map())
+	}
+	if len(ik.Keys) > 0 {
+		res.Set("ik", ik)
+	}
+	if len(transform.Keys) > 0 {
+		res.Set("transform", transform)
+	}
+	if len(path.Keys) > 0 {
+		res.Set("path", path.Map())
+	}
+	if len(deformSkins) > 0 {
+		skins := &jsonMap{}
+		for _, skin := range deformSkins {
+			skins.Set(skel.Skins[skin].Name, deform[skin].Map())
+		}
+		res.Set("deform", skins)
+	}
+	if len(drawOrder) > 0 {
+		res.Set("drawOrder", drawOrder)
+	}
+	if len(events) > 0 {
+		res.Set("events", events)
+	}
+	return res
+}
+
+// 除最后一帧外都写入曲线，线性的省略
+func exportKeyFrames(timeline *Timeline, export func(frame *KeyFrame, res *jsonMap)) []*jsonMap {
+	res := make([]*jsonMap, 0)
+	for i, frame := range timeline.KeyFrames {
+		item := &jsonMap{}
+		item.SetDefault("time", frame.Time, float32(0))
+		export(frame, item)
+		if i < len(timeline.KeyFrames)-1 && frame.Curve != nil {
+			switch frame.Curve.Type {
+			case CurveStepped:
+				item.Set("curve", "stepped")
+			case CurveBezier:
+				item.Set("curve", frame.Curve.Data[0].X())
+				item.SetDefault("c2", frame.Curve.Data[0].Y(), float32(0))
+				item.SetDefault("c3", frame.Curve.Data[1].X(), float32(1))
+				item.SetDefault("c4", frame.Curve.Data[1].Y(), float32(1))
+			}
+		}
+		res = append(res, item)
+	}
+	return res
+}
+
+func exportXY(res *jsonMap, value mgl32.Vec2, def float32) {
+	res.SetDefault("x", value.X(), def)
+	res.SetDefault("y", value.Y(), def)
+}
+
+// 只写入第一个到最后一个非 0 的偏移
+func exportDeform(frame *KeyFrame, res *jsonMap) {
+	deform := frame.Deform
+	if frame.Weight {
+		deform = make([]mgl32.Vec2, 0)
+		for _, items := range frame.WeightDeform {
+			deform = append(deform, items...)
+		}
+	}
+	values := make([]float32, 0)
+	for _, item := range deform {
+		values = append(values, item.X(), item.Y())
+	}
+	start, end := 0, len(values)
+	for start < end && values[start] == 0 {
+		start++
+	}
+	for end > start && values[end-1] == 0 {
+		end--
+	}
+	if start == end {
+		return
+	}
+	res.SetDefault("offset", start, 0)
+	res.Set("vertices", values[start:end])
+}
+
+// DrawOrder 解析时展开为每个插槽的新位置，这里还原为有变化插槽的偏移，按插槽顺序排列
+func exportDrawOrder(skel *SkeletonData, frame *KeyFrame) *jsonMap {
+	res := &jsonMap{}
+	res.SetDefault("time", frame.Time, float32(0))
+	offsets := make([]*jsonMap, 0)
+	for slot, order := range frame.DrawOrder {
+		if order != slot {
+			item := &jsonMap{}
+			item.Set("slot", skel.Slots[slot].Name)
+			item.Set("offset", order-slot)
+			offsets = append(offsets, item)
+		}
+	}
+	if len(offsets) > 0 {
+		res.Set("offsets", offsets)
+	}
+	return res
+}
+
+// 与 EventData 相同的数值省略
+func exportEvent(frame *KeyFrame) *jsonMap {
+	event := frame.Event
+	res := &jsonMap{}
+	res.SetDefault("time", frame.Time, float32(0))
+	res.Set("name", event.Data.Name)
+	res.SetDefault("int", event.Int, event.Data.Int)
+	res.SetDefault("float", event.Float, event.Data.Float)
+	res.SetDefault("string", event.String, event.Data.String)
+	if len(event.Data.AudioPath) > 0 {
+		res.SetDefault("volume", event.Volume, event.Data.Volume)
+		res.SetDefault("balance", event.Balance, event.Data.Balance)
+	}
+	return res
+}
+
+// 按首次出现的顺序分组的 jsonMap
+type jsonGroup struct {
+	Keys  []string
+	Items map[string]*jsonMap
+}
+
+func (g *jsonGroup) Get(key string) *jsonMap {
+	if g.Items == nil {
+		g.Items = make(map[string]*jsonMap)
+	}
+	if g.Items[key] == nil {
+		g.Items[key] = &jsonMap{}
+		g.Keys = append(g.Keys, key)
+	}
+	return g.Items[key]
+}
+
+func (g *jsonGroup) Map() *jsonMap {
+	res := &jsonMap{}
+	for _, key := range g.Keys {
+		res.Set(key, g.Items[key])
+	}
+	return res
+}
+
+func boneNames(skel *SkeletonData, bones []int) []string {
+	res := make([]string, 0)
+	for _, item := range bones {
+		res = append(res, skel.Bones[item].Name)
+	}
+	return res
+}
+
+func enumName(index int, items ...string) string {
+	if index < 0 || index >= len(items) {
+		panic(errors.New("invalid enum value"))
+	}
+	return items[index]
+}
+
+func formatRgba(color mgl32.Vec4) string {
+	return hex.EncodeToString([]byte{colorByte(color[0]), colorByte(color[1]), colorByte(color[2]), colorByte(color[3])})
+}
+
+// 与 parseRgb 对应，第一个分量为 0
+func formatRgb(color mgl32.Vec4) string {
+	return hex.EncodeToString([]byte{colorByte(color[1]), colorByte(color[2]), colorByte(color[3])})
+}
+
+func colorByte(value float32) byte {
+	return byte(math.Round(float64(max(0, min(1, value)) * 0xFF)))
+}
@@ -5,10 +5,7 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
-	"image/png"
 	"math"
-	"os"
-	"sort"
 
 	"github.com/go-gl/mathgl/mgl32"
 	"github.com/hajimehoshi/ebiten/v2"
@@ -17,117 +14,164 @@ import (
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 )
 
+// 骨骼的运行时数据，每个 Skeleton 实例一份
 type BoneNode struct {
 	Bone     *Bone
+	Index    int // 在 SkeletonData.Bones 中的下标
 	Parent   *BoneNode
 	Children []*BoneNode
+	// Local
+	LocalRotate float32
+	LocalPos    mgl32.Vec2
+	LocalScale  mgl32.Vec2
+	LocalShear  mgl32.Vec2
+	// World
+	WorldPos mgl32.Vec2
+	/*
+			Sx*cos , -Sy*sin
+			Sx*sin , Sy*cos
+			角度提取 tan = Sx*sin / Sx*cos
+			缩放提取 Sx = sqrt((Sx*cos)^2 + (Sx*sin)^2)
+		            Sy = sqrt((-Sy*sin)^2 + (Sy*cos)^2)
+	*/
+	Mat2   mgl32.Mat2 // 世界变换矩阵
+	Modify bool       // 标记世界坐标被约束修改过，局部数据已失效
+	Active bool       // 当前皮肤下是否生效
+	Offset mgl32.Vec2 // 只有根骨骼使用，实例整体的位置偏移
+}
+
+// 参考 spine-libgdx 3.8 Skeleton.updateWorldTransform 中的 updateCacheReset
+// 不在更新顺序中的骨骼（如 IK 子骨骼）不会被清除 Modify，需要每帧重置，否则会用上一帧的世界数据覆盖局部数据
+func (n *BoneNode) SetToSetupPose() {
+	n.LocalRotate = n.Bone.Rotate
+	n.LocalPos = n.Bone.Pos
+	n.LocalScale = n.Bone.Scale
+	n.LocalShear = n.Bone.Shear
+	n.Modify = false
 }
 
 func (n *BoneNode) Update() {
-	if n.Parent == nil { // 没有父节点局部坐标就是世界坐标
-		n.Bone.WorldPos = n.Bone.LocalPos
-		n.Bone.Mat2 = GScaleMat.
-			Mul2(Rotate(n.Bone.LocalRotate)).Mul2(Scale(n.Bone.LocalScale))
-	} else {
-		parent := n.Parent.Bone // 坐标计算毕竟是在父坐标系还是会受影响的
-		n.Bone.WorldPos = parent.Mat2.Mul2x1(n.Bone.LocalPos).Add(parent.WorldPos)
-		switch n.Bone.TransformMode {
-		case TransformNormal:
-			n.Bone.Mat2 = parent.Mat2.
-				Mul2(Rotate(n.Bone.LocalRotate)).Mul2(Scale(n.Bone.LocalScale))
-		case TransformOnlyTranslation:
-			n.Bone.Mat2 = GScaleMat.
-				Mul2(Rotate(n.Bone.LocalRotate)).Mul2(Scale(n.Bone.LocalScale))
-		case TransformNoRotationOrReflection: // 缩放要移除符号
-			mat2 := parent.Mat2
-			s := mat2[0]*mat2[0] + mat2[2]*mat2[2] // Sx^2
-			rotate := 0.0
-			if s > 0.0001 { // 主要是移除缩放的符号
-				s = mgl32.Abs(mat2[0]*mat2[3]-mat2[1]*mat2[2]) / s                      // Sy/Sx 正数
-				mat2[1] = mat2[2] * s                                                   // Sy*sin 统一符号
-				mat2[3] = mat2[0] * s                                                   // Sy*cos
-				rotate = math.Atan2(float64(mat2[2]), float64(mat2[0])) * 180 / math.Pi // 求角度
-				mat2[2] = -mat2[2]
-			} else {
-				mat2[0] = 0
-				mat2[2] = 0 // Sx 为 0 无法求角度，使用另外一个搭配
-				rotate = math.Atan2(float64(-mat2[1]), float64(mat2[3])) * 180 / math.Pi
-			} // 这里移除了符号，最后需要再添加回来
-			n.Bone.Mat2 = Scale(mgl32.Vec2{GSignX, GSignY}).Mul2(mat2).
-				Mul2(Rotate(n.Bone.LocalRotate - float32(rotate))).Mul2(Scale(n.Bone.LocalScale))
-		case TransformNoScale: // 只是没有缩放了，若是 负数缩放还是要保留负数 例如 缩放 -2 -> -1
-			scale := GetScale(parent.Mat2).Mul(1 / GScale) // 移除父对象的缩放量
-			n.Bone.Mat2 = parent.Mat2.
-				Mul2(Rotate(n.Bone.LocalRotate)).Mul2(Scale(Vec2Div(n.Bone.LocalScale, scale)))
-		case TransformNoScaleOrReflection: // 没有缩放且不保留负数 例如缩放 -2 ->  1
-			rotate := GetRotate(parent.Mat2)
-			n.Bone.Mat2 = GScaleMat.
-				Mul2(Rotate(n.Bone.LocalRotate + rotate)).Mul2(Scale(n.Bone.LocalScale))
-			fmt.Println("TransformNoScaleOrReflection in use") // 不常被使用，没怎么验证
-		default:
-			panic(fmt.Sprintf("invalid mode: %v", n.Bone.TransformMode))
-		} // 参考原项目必须使用矩阵变换，非等比缩放影响必须使用矩阵累加
-	}
+	n.updateTransform()
 	for _, child := range n.Children {
 		child.Update()
 	}
 }
 
-func (n *BoneNode) ApplyModify() {
-	if n.Bone.Modify {
-		n.Bone.Modify = false // 源头节点只更新 Mat3 即可
-		for _, item := range n.Children {
-			item.updateWorld() // 会清除子节点的 Modify
-		}
-	} else { // TODO 父子节点同时被修改怎么办？ 父节点递归更新会丢掉子节点的更新
-		for _, item := range n.Children {
-			item.ApplyModify()
-		}
+// 根据局部数据与父节点的世界数据计算自身的世界数据，参考 spine-libgdx 3.8 Bone.updateWorldTransform
+// GScaleMat 相当于原项目中 skeleton 的缩放
+func (n *BoneNode) updateTransform() {
+	local := TransformMat2(n.LocalRotate, n.LocalScale, n.LocalShear)
+	if n.Parent == nil { // 没有父节点局部坐标加上偏移就是世界坐标
+		n.WorldPos = n.LocalPos.Add(n.Offset)
+		n.Mat2 = GScaleMat.Mul2(local)
+		return
 	}
-}
-
-func (n *BoneNode) updateWorld() {
-	parent := n.Parent.Bone // 坐标计算毕竟是在父坐标系还是会受影响的
-	n.Bone.WorldPos = parent.Mat2.Mul2x1(n.Bone.LocalPos).Add(parent.WorldPos)
+	parent := n.Parent // 坐标计算毕竟是在父坐标系还是会受影响的
+	n.WorldPos = parent.Mat2.Mul2x1(n.LocalPos).Add(parent.WorldPos)
+	pa, pb, pc, pd := parent.Mat2.At(0, 0), parent.Mat2.At(0, 1), parent.Mat2.At(1, 0), parent.Mat2.At(1, 1)
 	switch n.Bone.TransformMode {
 	case TransformNormal:
-		n.Bone.Mat2 = parent.Mat2.
-			Mul2(Rotate(n.Bone.LocalRotate)).Mul2(Scale(n.Bone.LocalScale))
+		n.Mat2 = parent.Mat2.Mul2(local)
 	case TransformOnlyTranslation:
-		n.Bone.Mat2 = GScaleMat.
-			Mul2(Rotate(n.Bone.LocalRotate)).Mul2(Scale(n.Bone.LocalScale))
-	case TransformNoRotationOrReflection: // 缩放要移除符号
-		mat2 := parent.Mat2
-		s := mat2[0]*mat2[0] + mat2[2]*mat2[2] // Sx^2
-		rotate := 0.0
-		if s > 0.0001 { // 主要是移除缩放的符号
-			s = mgl32.Abs(mat2[0]*mat2[3]-mat2[1]*mat2[2]) / s                      // Sy/Sx 正数
-			mat2[1] = mat2[2] * s                                                   // Sy*sin 统一符号
-			mat2[3] = mat2[0] * s                                                   // Sy*cos
-			rotate = math.Atan2(float64(mat2[2]), float64(mat2[0])) * 180 / math.Pi // 求角度
-			mat2[2] = -mat2[2]
-		} else {
-			mat2[0] = 0
-			mat2[2] = 0 // Sx 为 0 无法求角度，使用另外一个搭配
-			rotate = math.Atan2(float64(-mat2[1]), float64(mat2[3])) * 180 / math.Pi
-		} // 这里移除了符号，最后需要再添加回来
-		n.Bone.Mat2 = Scale(mgl32.Vec2{GSignX, GSignY}).Mul2(mat2).
-			Mul2(Rotate(n.Bone.LocalRotate - float32(rotate))).Mul2(Scale(n.Bone.LocalScale))
-	case TransformNoScale: // 只是没有缩放了，若是 负数缩放还是要保留负数 例如 缩放 -2 -> -1
-		scale := GetScale(parent.Mat2).Mul(1 / GScale) // 移除父对象的缩放量
-		n.Bone.Mat2 = parent.Mat2.
-			Mul2(Rotate(n.Bone.LocalRotate)).Mul2(Scale(Vec2Div(n.Bone.LocalScale, scale)))
-	case TransformNoScaleOrReflection: // 没有缩放且不保留负数 例如缩放 -2 ->  1
-		rotate := GetRotate(parent.Mat2)
-		n.Bone.Mat2 = GScaleMat.
-			Mul2(Rotate(n.Bone.LocalRotate + rotate)).Mul2(Scale(n.Bone.LocalScale))
-		fmt.Println("TransformNoScaleOrReflection in use") // 不常被使用，没怎么验证
+		n.Mat2 = GScaleMat.Mul2(local)
+	case TransformNoRotationOrReflection: // 只继承父节点的缩放，缩放要移除符号
+		s := pa*pa + pc*pc
+		prx := float32(0) // 父节点的旋转
+		if s > 0.0001 {
+			s = mgl32.Abs(pa*pd-pb*pc) / s
+			pa /= GSignX * GScale
+			pc /= GSignY * GScale
+			pb = pc * s
+			pd = pa * s
+			prx = Atan2(pc, pa)
+		} else { // Sx 为 0 无法求角度，使用另外一个搭配
+			pa = 0
+			pc = 0
+			prx = 90 - Atan2(pd, pb)
+		}
+		local = TransformMat2(n.LocalRotate-prx, n.LocalScale, n.LocalShear)
+		n.Mat2 = GScaleMat.Mul2(mgl32.Mat2{pa, pc, -pb, pd}).Mul2(local)
+	case TransformNoScale, TransformNoScaleOrReflection: // 只继承父节点的旋转，NoScale 还要保留父节点的翻转
+		rad := float64(mgl32.DegToRad(n.LocalRotate))
+		cos, sin := float32(math.Cos(rad)), float32(math.Sin(rad))
+		za := (pa*cos + pb*sin) / (GSignX * GScale)
+		zc := (pc*cos + pd*sin) / (GSignY * GScale)
+		s := float32(math.Sqrt(float64(za*za + zc*zc)))
+		if s > 0.00001 {
+			s = 1 / s
+		}
+		za *= s
+		zc *= s
+		s = float32(math.Sqrt(float64(za*za + zc*zc)))
+		if n.Bone.TransformMode == TransformNoScale && (pa*pd-pb*pc < 0) != (GSignX < 0 != (GSignY < 0)) {
+			s = -s
+		}
+		rad = float64(mgl32.DegToRad(90 + Atan2(zc, za)))
+		zb, zd := float32(math.Cos(rad))*s, float32(math.Sin(rad))*s
+		local = TransformMat2(0, n.LocalScale, n.LocalShear)
+		n.Mat2 = GScaleMat.Mul2(mgl32.Mat2{za, zc, zb, zd}).Mul2(local)
 	default:
 		panic(fmt.Sprintf("invalid mode: %v", n.Bone.TransformMode))
 	} // 参考原项目必须使用矩阵变换，非等比缩放影响必须使用矩阵累加
-	n.Bone.Modify = false
-	for _, item := range n.Children {
-		item.updateWorld()
+}
+
+// 构建骨骼树，返回的节点与 bones 一一对应
+func NewBoneNodes(bones []*Bone) []*BoneNode {
+	nodes := make([]*BoneNode, 0)
+	for _, bone := range bones {
+		node := &BoneNode{
+			Bone:   bone,
+			Index:  len(nodes),
+			Active: true,
+		}
+		node.SetToSetupPose()
+		if bone.Parent >= 0 {
+			parent := nodes[bone.Parent]
+			node.Parent = parent
+			parent.Children = append(parent.Children, node)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// 父节点的世界变换，根节点使用全局缩放
+// 根节点的坐标不受全局缩放影响，原点取 WorldPos - GScaleMat·LocalPos 使 pPos + pMat·LocalPos 仍等于 WorldPos
+func (n *BoneNode) getParentWorld() (mgl32.Mat2, mgl32.Vec2) {
+	if n.Parent == nil {
+		return GScaleMat, n.WorldPos.Sub(GScaleMat.Mul2x1(n.LocalPos))
+	}
+	return n.Parent.Mat2, n.Parent.WorldPos
+}
+
+// 世界数据被约束修改后，反推出局部数据，参考 spine-libgdx 3.8 Bone.updateAppliedTransform
+func (n *BoneNode) updateAppliedTransform() {
+	n.Modify = false
+	var local mgl32.Mat2
+	if n.Parent == nil { // 根节点坐标只加了偏移，全局缩放只用于反推旋转、缩放与斜切
+		local = GScaleMat.Inv().Mul2(n.Mat2)
+		n.LocalPos = n.WorldPos.Sub(n.Offset)
+	} else { // 父矩阵的逆乘以自身矩阵就是局部矩阵
+		pMat, pPos := n.Parent.Mat2, n.Parent.WorldPos
+		local = pMat.Inv().Mul2(n.Mat2)
+		n.LocalPos = pMat.Inv().Mul2x1(n.WorldPos.Sub(pPos))
+	}
+	ra, rb, rc, rd := local.At(0, 0), local.At(0, 1), local.At(1, 0), local.At(1, 1)
+	sx := float32(math.Sqrt(float64(ra*ra + rc*rc)))
+	if sx > 0.0001 { // 斜切统一转换为 y 轴的斜切
+		det := ra*rd - rb*rc
+		sign := float32(1) // 行列式为负说明 y 轴被镜像
+		if det < 0 {
+			sign = -1
+		}
+		sy := float32(math.Sqrt(float64(rb*rb + rd*rd)))
+		n.LocalScale = mgl32.Vec2{sx, sign * sy}
+		n.LocalShear = mgl32.Vec2{0, Atan2(-(ra*rb+rc*rd)*sign, det*sign)}
+		n.LocalRotate = Atan2(rc, ra)
+	} else {
+		n.LocalScale = mgl32.Vec2{0, float32(math.Sqrt(float64(rb*rb + rd*rd)))}
+		n.LocalShear = mgl32.Vec2{}
+		n.LocalRotate = 90 - Atan2(rd, rb)
 	}
 }
 
@@ -141,29 +185,32 @@ type AttachmentItem struct {
 type Game struct {
 	// 原始数据
 	Atlas *Atlas
-	Skel  *Skel
+	Skel  *SkeletonData
 	// 扩展数据
-	Image       image.Image
-	BoneRoot    *BoneNode
-	OrderSlots  []*Slot
-	Attachments map[string]*AttachmentItem
-	Pos         mgl32.Vec2 // 调整位置
+	Image    image.Image
+	Skeleton *Skeleton // 运行时数据
+	// 皮肤
+	SkinIndex       int                             // 快捷键切换皮肤使用
+	AttachmentItems map[*Attachment]*AttachmentItem // 绘制用的附件缓存，用到时才创建
+	Pos             mgl32.Vec2                      // 调整位置
 	// 动画
 	AnimIndex      int
 	AnimController *AnimController
-	// 约束
-	ConstraintController *ConstraintController
+	// 包围盒 用于点击检测
+	Bounds       *SkeletonBounds
+	HitListeners []HitListener
+	// 绘制时的裁剪状态
+	Clipper *SkeletonClipping
 }
 
-func NewGame(atlas *Atlas, skel *Skel) *Game {
+func NewGame(atlas *Atlas, skel *SkeletonData) *Game {
 	res := &Game{Atlas: atlas, Skel: skel, Pos: mgl32.Vec2{640, 705}, AnimIndex: 0}
 	res.Image = res.loadImage()
-	res.BoneRoot = res.calculateBoneRoot()
-	res.OrderSlots = res.calculateOrderSlot()
-	res.Attachments = res.calculateAttachments()
-	res.AnimController = NewAnimController(skel.Animations[res.AnimIndex], skel, res.Attachments)
-	res.ConstraintController = NewConstraintController(skel.Bones, skel.PathConstraints, skel.TransformConstraints)
-	res.fillPathAttachment()
+	res.Skeleton = NewSkeleton(skel)
+	res.AttachmentItems = make(map[*Attachment]*AttachmentItem)
+	res.AnimController = NewAnimController(skel.Animations[res.AnimIndex], res.Skeleton)
+	res.Bounds = &SkeletonBounds{}
+	res.Clipper = &SkeletonClipping{}
 	return res
 }
 
@@ -181,75 +228,78 @@ func (g *Game) Update() error {
 		fmt.Println(g.Pos)
 	}
 	if inpututil.IsKeyJustPressed(ebiten.KeyJ) {
-		g.AnimIndex = (g.AnimIndex - 1 + len(g.Skel.Animations)) % len(g.Skel.Animations)
-		anim := g.Skel.Animations[g.AnimIndex]
-		g.AnimController = NewAnimController(anim, g.Skel, g.Attachments)
+		g.SetAnim((g.AnimIndex - 1 + len(g.Skel.Animations)) % len(g.Skel.Animations))
 	} else if inpututil.IsKeyJustPressed(ebiten.KeyK) {
-		g.AnimIndex = (g.AnimIndex + 1) % len(g.Skel.Animations)
-		anim := g.Skel.Animations[g.AnimIndex]
-		g.AnimController = NewAnimController(anim, g.Skel, g.Attachments)
-	}
-	g.BoneRoot.Bone.Pos = g.Pos
-	// 初始化数据  运行时数据默认为初始状态，防止动画没有改动为 零值
-	for i, slot := range g.Skel.Slots {
-		slot.CurrOrder = i
-		slot.CurrAttachment = slot.Attachment
-		slot.CurrColor = slot.Color
-		slot.CurrDarkColor = slot.DarkColor
-	}
-	for _, bone := range g.Skel.Bones {
-		bone.LocalRotate = bone.Rotate
-		bone.LocalPos = bone.Pos
-		bone.LocalScale = bone.Scale
+		g.SetAnim((g.AnimIndex + 1) % len(g.Skel.Animations))
+	} else if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		g.AnimController.Paused = !g.AnimController.Paused
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyL) {
+		g.SkinIndex = (g.SkinIndex + 1) % len(g.Skel.Skins)
+		g.Skeleton.SetSkin(g.Skel.Skins[g.SkinIndex])
 	}
-	for _, attachment := range g.Skel.Skin.Attachments {
-		if attachment.Weight {
-			attachment.CurrWeightVertices = make([][]*WeightVertex, 0)
-			for _, items := range attachment.WeightVertices {
-				temp := make([]*WeightVertex, 0)
-				for _, item := range items {
-					temp = append(temp, &WeightVertex{
-						Bone:   item.Bone,
-						Offset: item.Offset,
-						Weight: item.Weight,
-					})
-				}
-				attachment.CurrWeightVertices = append(attachment.CurrWeightVertices, temp)
+	g.Skeleton.Pos = g.Pos
+	// 初始化数据
+	g.Skeleton.SetToSetupPose()
+	// 更新数据
+	// 应用动画 都是局部坐标系下的对象或者坐标系无关对象
+	g.AnimController.Update(1 / float32(ebiten.TPS()))
+	// 计算世界数据并应用约束
+	g.Skeleton.UpdateWorldTransform()
+	g.Bounds.Update(g.Skeleton)
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		x, y := ebiten.CursorPosition()
+		point := mgl32.Vec2{float32(x), float32(y)}
+		if g.Bounds.AabbContainsPoint(point) {
+			if box := g.Bounds.ContainsPoint(point); box != nil {
+				g.fireHit(box)
 			}
-		} else {
-			attachment.CurrVertices = make([]mgl32.Vec2, len(attachment.Vertices))
-			copy(attachment.CurrVertices, attachment.Vertices)
 		}
 	}
-	for _, item := range g.Skel.PathConstraints {
-		item.CurrPosition = item.Position
-		item.CurrSpace = item.Space
-		item.CurrOffsetMix = item.OffsetMix
-		item.CurrRotateMix = item.RotateMix
+	return nil
+}
+
+// 鼠标点击到包围盒时在 Update 中回调
+type HitListener func(box *BoundingBox)
+
+func (g *Game) AddHitListener(listener HitListener) {
+	g.HitListeners = append(g.HitListeners, listener)
+}
+
+func (g *Game) fireHit(box *BoundingBox) {
+	for _, listener := range g.HitListeners {
+		listener(box)
+	}
+}
+
+// 切换动画，已注册的事件监听与播放设置保留
+func (g *Game) SetAnim(index int) {
+	old := g.AnimController
+	g.AnimIndex = index
+	g.AnimController = NewAnimController(g.Skel.Animations[index], g.Skeleton)
+	g.AnimController.Listeners = old.Listeners
+	g.AnimController.TimeScale, g.AnimController.Mode, g.AnimController.Paused = old.TimeScale, old.Mode, old.Paused
+}
+
+// 通过当前皮肤查找点附件，返回其世界坐标与旋转，用于在点上放置特效，需要在 Update 之后调用
+func (g *Game) GetPointWorld(slotName, attachmentName string) (mgl32.Vec2, float32, bool) {
+	slot := g.Skeleton.FindSlot(slotName)
+	if slot == nil {
+		return mgl32.Vec2{}, 0, false
 	}
-	for _, item := range g.Skel.TransformConstraints {
-		item.CurrScaleMix = item.ScaleMix
-		item.CurrRotateMix = item.RotateMix
-		item.CurrOffsetMix = item.OffsetMix
+	attachment := g.Skeleton.GetAttachment(slot.Slot.Index, attachmentName)
+	if attachment == nil || attachment.Type != AttachmentPoint {
+		return mgl32.Vec2{}, 0, false
 	}
-	// 更新数据
-	// 应用动画 都是局部坐标系下的对象或者坐标系无关对象
-	g.AnimController.Update()
-	// 计算出世界坐标 世界旋转 世界缩放 与 世界矩阵
-	g.BoneRoot.Update()
-	// 对世界坐标下的对象应用约束
-	//g.ConstraintController.Update()
-	//g.BoneRoot.ApplyModify() // 约束修改对象后需要关联更新
-	sort.Slice(g.OrderSlots, func(i, j int) bool {
-		return g.OrderSlots[i].CurrOrder < g.OrderSlots[j].CurrOrder
-	})
-	return nil
+	bone := g.Skeleton.Bones[slot.Slot.Bone]
+	return attachment.ComputeWorldPosition(bone), attachment.ComputeWorldRotation(bone), true
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
-	for _, slot := range g.OrderSlots {
+	for _, slot := range g.Skeleton.DrawOrder {
 		g.drawSlot(slot, screen)
+		g.Clipper.ClipEnd(slot.Slot)
 	}
+	g.Clipper.ClipEnd(nil)
 	ebitenutil.DebugPrint(screen, g.AnimController.GetAnimName())
 }
 
@@ -266,82 +316,61 @@ func NewVertex(dx, dy, sx, sy float32) ebiten.Vertex {
 	}
 }
 
-func (g *Game) drawSlot(slot *Slot, screen *ebiten.Image) {
-	if slot.Bone < 0 || len(slot.CurrAttachment) == 0 {
+func (g *Game) drawSlot(slot *SlotState, screen *ebiten.Image) {
+	attachment := g.Skeleton.GetSlotAttachment(slot)
+	if attachment == nil {
 		return // 无效值
 	}
-	item := g.Attachments[AttachmentKey(slot.CurrAttachment, slot.Index)]
+	item := g.getAttachmentItem(attachment)
 	if item.Image == nil {
 		return // 无需绘制
 	}
+	if attachment.Type == AttachmentClip { // 裁剪附件本身不绘制
+		g.Clipper.ClipStart(attachment, g.Skeleton.ComputeWorldVertices(attachment, slot.Slot.Bone))
+		return
+	}
 	bound := item.Image.Bounds()
 	w, h := float32(bound.Dx()), float32(bound.Dy())
-	vertices := make([]ebiten.Vertex, 0)
-	indices := make([]uint16, 0)
-	attachment := item.Attachment
+	var points, uvs []mgl32.Vec2
+	var indices []uint16
 	currClr := Vec4Mul(slot.CurrColor, slot.CurrDarkColor)
 	// 不同组件的展示是 动画控制的，默认会全部展示
 	if attachment.Type == AttachmentRegion {
-		bone := g.Skel.Bones[slot.Bone]
+		bone := g.Skeleton.Bones[slot.Slot.Bone]
 		worldPos := bone.Mat2.Mul2x1(attachment.Pos).Add(bone.WorldPos)
 		mat2 := bone.Mat2.Mul2(Rotate(attachment.Rotate)).Mul2(Scale(attachment.Scale))
-		v0 := mat2.Mul2x1(mgl32.Vec2{-w / 2, h / 2}).Add(worldPos)
-		v1 := mat2.Mul2x1(mgl32.Vec2{w / 2, h / 2}).Add(worldPos)
-		v2 := mat2.Mul2x1(mgl32.Vec2{w / 2, -h / 2}).Add(worldPos)
-		v3 := mat2.Mul2x1(mgl32.Vec2{-w / 2, -h / 2}).Add(worldPos)
-		vertices = append(vertices, NewVertex(v0.X(), v0.Y(), 0, 0))
-		vertices = append(vertices, NewVertex(v1.X(), v1.Y(), w, 0))
-		vertices = append(vertices, NewVertex(v2.X(), v2.Y(), w, h))
-		vertices = append(vertices, NewVertex(v3.X(), v3.Y(), 0, h))
+		points = []mgl32.Vec2{
+			mat2.Mul2x1(mgl32.Vec2{-w / 2, h / 2}).Add(worldPos),
+			mat2.Mul2x1(mgl32.Vec2{w / 2, h / 2}).Add(worldPos),
+			mat2.Mul2x1(mgl32.Vec2{w / 2, -h / 2}).Add(worldPos),
+			mat2.Mul2x1(mgl32.Vec2{-w / 2, -h / 2}).Add(worldPos),
+		}
+		uvs = []mgl32.Vec2{{0, 0}, {w, 0}, {w, h}, {0, h}}
 		indices = []uint16{0, 1, 2, 0, 2, 3}
 		currClr = Vec4Mul(currClr, attachment.Color)
-	} else if attachment.Type == AttachmentMesh {
-		if attachment.Weight {
-			for i, uv := range attachment.UVs {
-				res := mgl32.Vec2{}
-				for _, vec := range attachment.CurrWeightVertices[i] {
-					bone := g.Skel.Bones[vec.Bone]
-					temp := bone.Mat2.Mul2x1(vec.Offset).Add(bone.WorldPos)
-					res = res.Add(temp.Mul(vec.Weight))
-				}
-				vertices = append(vertices, NewVertex(res.X(), res.Y(), uv.X()*w, uv.Y()*h))
-			}
-		} else {
-			bone := g.Skel.Bones[slot.Bone]
-			for i, uv := range attachment.UVs {
-				vec := bone.Mat2.Mul2x1(attachment.CurrVertices[i]).Add(bone.WorldPos)
-				vertices = append(vertices, NewVertex(vec.X(), vec.Y(), uv.X()*w, uv.Y()*h))
-			}
+	} else if attachment.Type == AttachmentMesh || attachment.Type == AttachmentLinkMesh {
+		points = g.Skeleton.ComputeWorldVertices(attachment, slot.Slot.Bone)
+		for _, uv := range attachment.UVs {
+			uvs = append(uvs, mgl32.Vec2{uv.X() * w, uv.Y() * h})
 		}
 		indices = attachment.Indices
 		currClr = Vec4Mul(currClr, attachment.Color)
-	} else if attachment.Type == AttachmentClip {
-		if attachment.Weight { // 不需要 UV
-			for _, wv := range attachment.CurrWeightVertices {
-				res := mgl32.Vec2{}
-				for _, vec := range wv {
-					bone := g.Skel.Bones[vec.Bone]
-					temp := bone.Mat2.Mul2x1(vec.Offset).Add(bone.WorldPos)
-					res = res.Add(temp.Mul(vec.Weight))
-				}
-				vertices = append(vertices, NewVertex(res.X(), res.Y(), 0, 0))
-			}
-		} else {
-			bone := g.Skel.Bones[slot.Bone]
-			for _, vertex := range attachment.CurrVertices {
-				vec := bone.Mat2.Mul2x1(vertex).Add(bone.WorldPos)
-				vertices = append(vertices, NewVertex(vec.X(), vec.Y(), 0, 0))
-			}
-		}
-		for i := 2; i < len(vertices); i++ {
-			indices = append(indices, 0, uint16(i-1), uint16(i))
-		} // 蒙版展示没有生效
 	} else {
 		panic("unknown attachment type")
 	}
+	if g.Clipper.IsClipping() {
+		points, uvs, indices = g.Clipper.ClipTriangles(points, uvs, indices)
+		if len(indices) == 0 {
+			return // 完全被裁掉
+		}
+	}
+	vertices := make([]ebiten.Vertex, 0, len(points))
+	for i, point := range points {
+		vertices = append(vertices, NewVertex(point.X(), point.Y(), uvs[i].X(), uvs[i].Y()))
+	}
 	item.ColorM.Reset()
 	item.ColorM.Scale(float64(currClr[0]), float64(currClr[1]), float64(currClr[2]), float64(currClr[3]))
-	item.Option.Blend = BlendMap[slot.BlendMode]
+	item.Option.Blend = BlendMap[slot.Slot.BlendMode]
 	colorm.DrawTriangles(screen, vertices, indices, item.Image, item.ColorM, item.Option)
 }
 
@@ -349,44 +378,17 @@ func (g *Game) Layout(w, h int) (int, int) {
 	return w, h
 }
 
-func (g *Game) calculateBoneRoot() *BoneNode {
-	nodes := make([]*BoneNode, 0)
-	for _, bone := range g.Skel.Bones {
-		node := &BoneNode{
-			Bone: bone,
-		}
-		if bone.Parent >= 0 {
-			parent := nodes[bone.Parent]
-			node.Parent = parent
-			parent.Children = append(parent.Children, node)
-		}
-		nodes = append(nodes, node)
+func (g *Game) getAttachmentItem(attachment *Attachment) *AttachmentItem {
+	if res, ok := g.AttachmentItems[attachment]; ok {
+		return res
 	}
-	return nodes[0] // 第一个就是根骨骼
-}
-
-func (g *Game) calculateOrderSlot() []*Slot {
-	res := make([]*Slot, len(g.Skel.Slots)) // 原始的顺序不要动
-	copy(res, g.Skel.Slots)
-	return res
-}
-
-func (g *Game) calculateAttachments() map[string]*AttachmentItem {
-	res := make(map[string]*AttachmentItem)
-	for _, item := range g.Skel.Skin.Attachments {
-		if item.Type == AttachmentMesh || item.Type == AttachmentRegion || item.Type == AttachmentClip {
-			res[AttachmentKey(item.Name, item.Slot)] = &AttachmentItem{
-				Attachment: item,
-				Image:      g.createImage(item.Path),
-				Option:     &colorm.DrawTrianglesOptions{},
-				ColorM:     colorm.ColorM{},
-			}
-		} else {
-			res[AttachmentKey(item.Name, item.Slot)] = &AttachmentItem{
-				Attachment: item,
-			}
-		}
+	res := &AttachmentItem{Attachment: attachment}
+	if attachment.Type == AttachmentMesh || attachment.Type == AttachmentLinkMesh || attachment.Type == AttachmentRegion || attachment.Type == AttachmentClip {
+		res.Image = g.createImage(attachment.Path)
+		res.Option = &colorm.DrawTrianglesOptions{}
+		res.ColorM = colorm.ColorM{}
 	}
+	g.AttachmentItems[attachment] = res
 	return res
 }
 
@@ -472,18 +474,7 @@ func (g *Game) createImage(path string) *ebiten.Image {
 }
 
 func (g *Game) loadImage() image.Image {
-	file, err := os.Open(BasePath + g.Atlas.Image)
-	HandleErr(err)
-	img, err := png.Decode(file)
+	img, err := g.Atlas.LoadImage()
 	HandleErr(err)
-	file.Close()
 	return img
 }
-
-func (g *Game) fillPathAttachment() {
-	for _, item := range g.Skel.PathConstraints {
-		slot := g.Skel.Slots[item.Target]
-		item.Attachment = g.Attachments[AttachmentKey(slot.Attachment, slot.Index)].Attachment
-		item.Bone = slot.Bone
-	}
-}
@@ -1,15 +1,24 @@
 package main
 
 import (
+	"os"
+
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
 func main() {
-	// 缺失 IK 的支持
-	atlas := ParseAtlas("res/dyn_illust_2025_shu/dyn_illust_char_2025_shu.atlas")
-	skel := ParseSkel("res/dyn_illust_2025_shu/dyn_illust_char_2025_shu.skel")
+	// 模型路径相对于工作目录，也可以换成 embed.FS 或 zip.Reader
+	fsys := os.DirFS(".")
+	skelPath := "res/dyn_illust_2025_shu/dyn_illust_char_2025_shu.skel"
+	atlas, err := LoadAtlasFS(fsys, "res/dyn_illust_2025_shu/dyn_illust_char_2025_shu.atlas")
+	HandleErr(err)
+	skel, err := LoadSkelFS(fsys, skelPath)
+	HandleErr(err)
 
 	ebiten.SetWindowSize(1280, 720)
-	err := ebiten.RunGame(NewGame(atlas, skel))
+	game := NewGame(atlas, skel)
+	// 播放事件音频，不需要可以去掉
+	game.AnimController.AddEventListener(NewAudioPlayer(fsys, skelPath, skel.Header).OnEvent)
+	err = ebiten.RunGame(game)
 	HandleErr(err)
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 解析失败的位置与原因
+type ParseError struct {
+	Section string // 出错的部分，例如 bones slots skin:default animation:idle
+	Offset  int64  // 出错时已读取的字节数，atlas 为出错行的起始偏移
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse %s at offset %d: %v", e.Section, e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+const (
+	SkelReaderSize = 64 << 10
+)
+
+// 带缓冲的二进制输入，记录读取位置与当前解析的部分，出错时用于生成 ParseError
+type SkelReader struct {
+	Reader  *bufio.Reader
+	Offset  int64
+	Section string
+	Version SpineVersion // 解析头部后确定，各版本格式不同
+}
+
+// 主次版本号，3.8.99 为 {3, 8}
+type SpineVersion struct {
+	Major int
+	Minor int
+}
+
+func (v SpineVersion) AtLeast(major, minor int) bool {
+	return v.Major > major || v.Major == major && v.Minor >= minor
+}
+
+// 3.8.99 -> {3, 8}，不是版本号时返回 false
+func parseVersion(version string) (SpineVersion, bool) {
+	items := strings.SplitN(version, ".", 3)
+	if len(items) < 2 {
+		return SpineVersion{}, false
+	}
+	major, err := strconv.Atoi(items[0])
+	if err != nil {
+		return SpineVersion{}, false
+	}
+	minor, err := strconv.Atoi(items[1])
+	if err != nil {
+		return SpineVersion{}, false
+	}
+	return SpineVersion{major, minor}, true
+}
+
+func NewSkelReader(reader io.Reader) *SkelReader {
+	return &SkelReader{Reader: bufio.NewReaderSize(reader, SkelReaderSize), Section: "header", Version: SpineVersion{3, 8}}
+}
+
+func (r *SkelReader) Read(bs []byte) (int, error) {
+	n, err := r.Reader.Read(bs)
+	r.Offset += int64(n)
+	return n, err
+}
+
+// 读取 count 字节，不足时 panic，返回的数据只在下次读取前有效
+func (r *SkelReader) next(count int) []byte {
+	if count <= SkelReaderSize {
+		bs, err := r.Reader.Peek(count)
+		if len(bs) < count {
+			HandleErr(unexpectedEOF(err))
+		}
+		_, _ = r.Reader.Discard(count)
+		r.Offset += int64(count)
+		return bs
+	}
+	// 长度来自文件，错误数据可能很大，按实际读取避免申请巨大内存
+	res, err := io.ReadAll(io.LimitReader(r, int64(count)))
+	HandleErr(err)
+	if len(res) < count {
+		HandleErr(io.ErrUnexpectedEOF)
+	}
+	return res
+}
+
+// 字段读到一半结束的都是数据不完整
+func unexpectedEOF(err error) error {
+	if err == nil || err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// 内部解析出错直接 panic，在入口处统一转换为 ParseError
+func recoverParseErr(err *error, section func() (string, int64)) {
+	temp := recover()
+	if temp == nil {
+		return
+	}
+	name, offset := section()
+	res := &ParseError{Section: name, Offset: offset}
+	if e, ok := temp.(error); ok {
+		res.Err = e
+	} else {
+		res.Err = fmt.Errorf("%v", temp)
+	}
+	*err = res
+}
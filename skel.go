@@ -6,16 +6,27 @@ import (
 	"github.com/go-gl/mathgl/mgl32"
 	"github.com/hajimehoshi/ebiten/v2"
 	"io"
+	"io/fs"
 	"math"
 	"os"
+	"slices"
 	"sort"
+	"strconv"
 )
 
+// 支持解析的版本，4.x 解析后转换为 3.8 的数据
+var SupportedVersions = []SpineVersion{{3, 7}, {3, 8}, {4, 0}, {4, 1}}
+
 type SkelHeader struct {
 	Hash    string // 校验文件
 	Version string // 校验版本
 	Pos     mgl32.Vec2
 	Size    mgl32.Vec2
+	// 非必要数据，导出时勾选了 nonessential 才有
+	Nonessential bool
+	Fps          float32
+	ImagesPath   string
+	AudioPath    string
 }
 
 const (
@@ -33,24 +44,10 @@ type Bone struct {
 	Pos           mgl32.Vec2
 	Scale         mgl32.Vec2
 	Shear         mgl32.Vec2
-	Length        float32 // IK 使用的 暂时没用
-	TransformMode uint8   // 继承父节点那些变换属性
-	SkinRequire   bool    // 多皮肤使用的
-	// 运行时数据  Local
-	LocalRotate float32
-	LocalPos    mgl32.Vec2
-	LocalScale  mgl32.Vec2
-	// World
-	WorldPos mgl32.Vec2
-	/*
-			Sx*cos , -Sy*sin
-			Sx*sin , Sy*cos
-			角度提取 tan = Sx*sin / Sx*cos
-			缩放提取 Sx = sqrt((Sx*cos)^2 + (Sx*sin)^2)
-		            Sy = sqrt((-Sy*sin)^2 + (Sy*cos)^2)
-	*/
-	Mat2   mgl32.Mat2 // 世界变换矩阵
-	Modify bool       // 标记世界坐标被修改过，需要重新递归计算
+	Length        float32    // IK 使用的 暂时没用
+	TransformMode uint8      // 继承父节点那些变换属性
+	SkinRequire   bool       // 多皮肤使用的，只有当前皮肤包含时才生效
+	Color         mgl32.Vec4 // 非必要数据，编辑器中骨骼的颜色
 }
 
 const (
@@ -96,10 +93,6 @@ type Slot struct {
 	Attachment       string
 	BlendMode        uint8
 	Index            int
-	// 运行时值
-	CurrOrder                int
-	CurrAttachment           string
-	CurrColor, CurrDarkColor mgl32.Vec4
 }
 
 type WeightVertex struct {
@@ -119,11 +112,12 @@ const (
 )
 
 type Attachment struct {
-	Name           string
-	Slot           int // name + slot 才是唯一的
+	Name           string // 皮肤中的占位名，插槽与动画都通过它引用附件
+	RealName       string // 附件真实名称，为空时与 Name 相同
+	Slot           int    // name + slot 才是唯一的
 	Type           uint8
 	Path           string
-	Color          mgl32.Vec4
+	Color          mgl32.Vec4 // 对于 BoundBox Path Clip Point 是非必要数据，编辑器中的颜色
 	Weight         bool
 	Vertices       []mgl32.Vec2
 	WeightVertices [][]*WeightVertex
@@ -131,24 +125,48 @@ type Attachment struct {
 	Rotate float32
 	Pos    mgl32.Vec2
 	Scale  mgl32.Vec2
-	Size   mgl32.Vec2 // 用来确定中心点与 UV 计算
+	Size   mgl32.Vec2 // 用来确定中心点与 UV 计算，Mesh 的为非必要数据
 	// AttachmentMesh
 	UVs        []mgl32.Vec2
 	Indices    []uint16
-	HullLength int // 凸包体边数 暂不使用
+	HullLength int      // 凸包体边数 暂不使用
+	Edges      []uint16 // 非必要数据，编辑器中网格的边
+	// AttachmentLinkMesh 共享父网格的顶点、UV 与三角形，只有图片与颜色是自己的
+	ParentSkin    string      // 父网格所在皮肤，为空是默认皮肤
+	ParentName    string      // 父网格的占位名
+	InheritDeform bool        // 是否跟随父网格的变形动画
+	Parent        *Attachment // 所有皮肤解析完后才能找到
 	// AttachmentPath
 	Close         bool
 	ConstantSpeed bool
 	Lengths       []float32 // 每 3 个点一组，前两个点为贝塞尔曲线控制点，最后一个点为实际点 长度就是每组点到原点的距离
 	// AttachmentClip
 	EndSlot int
-	// 运行时数据
-	CurrVertices       []mgl32.Vec2
-	CurrWeightVertices [][]*WeightVertex
+	// 4.1 新增的序列帧，Path 已换成初始帧的图片
+	Sequence *Sequence
+}
+
+// 参考 spine-libgdx 4.1 Sequence，每帧图片为 Path + 补 0 到 Digits 位的 Start + 帧下标
+type Sequence struct {
+	Count      int
+	Start      int
+	Digits     int
+	SetupIndex int // 初始显示的帧
 }
 
+func (s *Sequence) GetPath(path string, index int) string {
+	return fmt.Sprintf("%s%0*d", path, s.Digits, s.Start+index)
+}
+
+// http://zh.esotericsoftware.com/spine-skins
 type Skin struct {
-	Attachments []*Attachment
+	Name string
+	// 皮肤需要的骨骼与约束，SkinRequire 的只有当前皮肤包含时才生效，默认皮肤为空
+	Bones                []int
+	IkConstraints        []int
+	TransformConstraints []int
+	PathConstraints      []int
+	Attachments          []*Attachment
 }
 
 const (
@@ -177,7 +195,7 @@ type KeyFrame struct {
 	Offset mgl32.Vec2
 	// TimelineScale
 	Scale mgl32.Vec2
-	// Timeline
+	// TimelineShear
 	Shear mgl32.Vec2
 	// TimelineDrawOrder
 	DrawOrder []int // 对应槽位的新位置
@@ -195,6 +213,14 @@ type KeyFrame struct {
 	// TimelinePathConstraintSpace
 	Space float32
 	// TimelinePathConstraintMix 复用上面的  RotateMix  OffsetMix
+	// TimelineIkConstraint
+	Mix           float32
+	Softness      float32
+	BendDirection int
+	Compress      bool
+	Stretch       bool
+	// TimelineEvent
+	Event *Event
 }
 
 const (
@@ -237,23 +263,63 @@ const (
 type Timeline struct {
 	Type                uint8
 	Slot                int
+	Skin                int // TimelineDeform 使用，Attachment 所在的皮肤
 	Bone                int
 	Attachment          string
+	IkConstraint        int
 	TransformConstraint int
 	PathConstraint      int
 	KeyFrames           []*KeyFrame
 }
 
+// http://zh.esotericsoftware.com/spine-events
+type EventData struct {
+	Name      string
+	Int       int
+	Float     float32
+	String    string
+	AudioPath string  // 相对于音频目录（头部的 AudioPath，没有时为 skel 文件所在目录）的路径，为空说明没有音频
+	Volume    float32 // 0~1
+	Balance   float32 // 左右声道平衡 -1~1
+}
+
+// 动画中触发的事件，数值默认取 EventData 中的，关键帧可以覆盖
+type Event struct {
+	Data    *EventData
+	Time    float32
+	Int     int
+	Float   float32
+	String  string
+	Volume  float32
+	Balance float32
+}
+
 type Animation struct {
 	Name      string
 	Timelines []*Timeline
 	Duration  float32
 }
 
+// http://zh.esotericsoftware.com/spine-ik-constraints
+type IkConstraint struct {
+	Name        string
+	Order       int // 作用顺序
+	SkinRequire bool
+	Bones       []int // 1 个或 2 个骨骼
+	Target      int   // 目标骨骼
+	Mix         float32
+	Softness    float32 // 两个骨骼时，接近伸直时的减速距离
+	// 两个骨骼时的弯曲方向 1 or -1
+	BendDirection int
+	Compress      bool // 一个骨骼时，距离不足时是否压缩
+	Stretch       bool // 距离超出时是否拉伸
+	Uniform       bool // 拉伸压缩时是否等比缩放
+}
+
 type TransformConstraint struct {
 	Name        string
-	Order       int  // 作用顺序
-	SkinRequire bool // 暂不使用
+	Order       int // 作用顺序
+	SkinRequire bool
 	// Bones 受 Target 的影响
 	Bones           []int
 	Target          int
@@ -268,10 +334,6 @@ type TransformConstraint struct {
 	OffsetMix float32
 	ScaleMix  float32
 	ShearMix  float32
-	// 运行时数据
-	CurrRotateMix float32
-	CurrOffsetMix float32
-	CurrScaleMix  float32
 }
 
 const (
@@ -280,9 +342,10 @@ const (
 )
 
 const (
-	SpaceLength  = 0
-	SpaceFixed   = 1
-	SpacePercent = 2
+	SpaceLength       = 0
+	SpaceFixed        = 1
+	SpacePercent      = 2
+	SpaceProportional = 3 // 4.0 新增，运行时暂按 fixed 处理
 )
 
 const ( // 各种确定在路径上旋转值的方式
@@ -304,57 +367,90 @@ type PathConstraint struct {
 	Space                               float32 // 多个 Bones 在路径上的间距
 	RotateMix                           float32
 	OffsetMix                           float32
-	// 运行时数据
-	Attachment    *Attachment // 对应的 Path
-	Bone          int         // 对应的骨骼只有 Path 点非 Weight 时用的上，一般都是 Weight 的
-	CurrPosition  float32
-	CurrSpace     float32
-	CurrRotateMix float32
-	CurrOffsetMix float32
 }
 
-type Skel struct {
+// 解析出的只读数据，可以被多个 Skeleton 实例共享，运行时数据都在 Skeleton 上
+type SkeletonData struct {
 	Header               *SkelHeader
 	Bones                []*Bone
 	Slots                []*Slot
+	IkConstraints        []*IkConstraint
 	TransformConstraints []*TransformConstraint
 	PathConstraints      []*PathConstraint
-	Skin                 *Skin // 暂时只支持默认皮肤，不支持换肤
+	Skin                 *Skin   // 默认皮肤
+	Skins                []*Skin // 第一个为默认皮肤
+	Events               []*EventData
 	Animations           []*Animation
 }
 
-func ParseSkel(path string) *Skel {
-	reader, err := os.Open(BasePath + path)
+// path 为本地路径，失败 panic
+func ParseSkel(path string) *SkeletonData {
+	file, err := os.Open(path)
+	HandleErr(err)
+	defer file.Close()
+	res, err := LoadSkel(file)
 	HandleErr(err)
+	return res
+}
+
+// 从 fsys 中加载，可以是目录、embed.FS 或 zip.Reader
+func LoadSkelFS(fsys fs.FS, name string) (*SkeletonData, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadSkel(file)
+}
+
+// 解析失败返回 *ParseError，不会 panic
+func LoadSkel(r io.Reader) (res *SkeletonData, err error) {
+	reader := NewSkelReader(r)
+	defer recoverParseErr(&err, func() (string, int64) {
+		return reader.Section, reader.Offset
+	})
 	header := parseSkelHeader(reader)
+	reader.Section = "strings"
 	strings := parseStrings(reader)
-	bones := parseBones(reader)
+	reader.Section = "bones"
+	bones := parseBones(reader, header.Nonessential)
+	reader.Section = "slots"
 	slots := parseSlots(reader, strings)
-	skipIKConstraints(reader) // 暂时不使用IK约束
+	reader.Section = "ik constraints"
+	ikConstraints := parseIkConstraints(reader)
+	reader.Section = "transform constraints"
 	transformConstraints := parseTransformConstraints(reader)
+	reader.Section = "path constraints"
 	pathConstraints := parsePathConstraints(reader)
-	skin := parseSkin(reader, strings)
-	skipEvents(reader, strings)
-	animations := parseAnimations(reader, strings, slots, skin)
-	return &Skel{
+	skins := parseSkins(reader, strings, header.Nonessential)
+	reader.Section = "linked meshes"
+	linkMeshes(skins)
+	reader.Section = "events"
+	events := parseEvents(reader, strings)
+	reader.Section = "animations"
+	animations := parseAnimations(reader, strings, slots, skins, events)
+	return &SkeletonData{
 		Header:               header,
 		Bones:                bones,
 		Slots:                slots,
+		IkConstraints:        ikConstraints,
 		TransformConstraints: transformConstraints,
 		PathConstraints:      pathConstraints,
-		Skin:                 skin,
+		Skin:                 skins[0],
+		Skins:                skins,
+		Events:               events,
 		Animations:           animations,
-	}
+	}, nil
 }
 
-func parsePathConstraints(reader io.Reader) []*PathConstraint {
+func parsePathConstraints(reader *SkelReader) []*PathConstraint {
 	res := make([]*PathConstraint, 0)
 	count := readInt(reader)
 	for i := 0; i < count; i++ {
 		temp := &PathConstraint{
 			Name:        readStr(reader),
 			Order:       readInt(reader),
-			SkinRequire: readBool(reader),
+			SkinRequire: readSkinRequire(reader),
 		}
 		bCount := readInt(reader)
 		for j := 0; j < bCount; j++ {
@@ -369,22 +465,22 @@ func parsePathConstraints(reader io.Reader) []*PathConstraint {
 		temp.Space = readF4(reader)
 		temp.RotateMix = readF4(reader)
 		temp.OffsetMix = readF4(reader)
+		if reader.Version.AtLeast(4, 0) { // 4.x 平移的 mix 分为 x y
+			checkMixXY("offset", temp.OffsetMix, readF4(reader))
+		}
 		res = append(res, temp)
 	}
-	sort.Slice(res, func(i, j int) bool {
-		return res[i].Order < res[j].Order
-	})
 	return res
 }
 
-func parseTransformConstraints(reader io.Reader) []*TransformConstraint {
+func parseTransformConstraints(reader *SkelReader) []*TransformConstraint {
 	res := make([]*TransformConstraint, 0)
 	count := readInt(reader)
 	for i := 0; i < count; i++ {
 		temp := &TransformConstraint{
 			Name:        readStr(reader),
 			Order:       readInt(reader),
-			SkinRequire: readBool(reader),
+			SkinRequire: readSkinRequire(reader),
 		}
 		boneCount := readInt(reader)
 		for j := 0; j < boneCount; j++ {
@@ -395,33 +491,52 @@ func parseTransformConstraints(reader io.Reader) []*TransformConstraint {
 		temp.Relative = readBool(reader)
 		temp.Rotate = readF4(reader)
 		vs := [2]mgl32.Vec2{}
-		readAny(reader, &vs)
+		readVec2s(reader, vs[:])
 		temp.Offset = vs[0]
 		temp.Scale = vs[1]
 		temp.ShearY = readF4(reader)
-		temp.RotateMix = readF4(reader)
-		temp.OffsetMix = readF4(reader)
-		temp.ScaleMix = readF4(reader)
-		temp.ShearMix = readF4(reader)
+		if reader.Version.AtLeast(4, 0) { // 4.x 每个分量有自己的 mix，依次为 rotate x y scaleX scaleY shearY
+			mixes := [6]float32{}
+			for j := range mixes {
+				mixes[j] = readF4(reader)
+			}
+			checkMixXY("offset", mixes[1], mixes[2])
+			checkMixXY("scale", mixes[3], mixes[4])
+			temp.RotateMix, temp.OffsetMix, temp.ScaleMix, temp.ShearMix = mixes[0], mixes[1], mixes[3], mixes[5]
+		} else {
+			temp.RotateMix = readF4(reader)
+			temp.OffsetMix = readF4(reader)
+			temp.ScaleMix = readF4(reader)
+			temp.ShearMix = readF4(reader)
+		}
 		res = append(res, temp)
 	}
-	sort.Slice(res, func(i, j int) bool {
-		return res[i].Order < res[j].Order
-	})
 	return res
 }
 
-func parseAnimations(reader io.Reader, strings []string, slots []*Slot, skin *Skin) []*Animation {
+// 4.x 的 x y 分别有 mix，3.8 的数据只有一个，不相同时无法正确表现，直接拒绝
+func checkMixXY(name string, x, y float32) {
+	if x != y {
+		panic(fmt.Errorf("%s mix x %v and y %v differ, separate x y mix is not supported", name, x, y))
+	}
+}
+
+func parseAnimations(reader *SkelReader, strings []string, slots []*Slot, skins []*Skin, events []*EventData) []*Animation {
 	count := readInt(reader)
 	animations := make([]*Animation, 0)
 	for i := 0; i < count; i++ {
-		animations = append(animations, parseAnimation(reader, strings, slots, skin))
+		if reader.Version.AtLeast(4, 0) {
+			animations = append(animations, parseAnimation4(reader, strings, slots, skins, events))
+		} else {
+			animations = append(animations, parseAnimation(reader, strings, slots, skins, events))
+		}
 	}
 	return animations
 }
 
-func parseAnimation(reader io.Reader, strings []string, slots []*Slot, skin *Skin) *Animation {
+func parseAnimation(reader *SkelReader, strings []string, slots []*Slot, skins []*Skin, events []*EventData) *Animation {
 	name := readStr(reader)
+	reader.Section = "animation:" + name
 	timelines := make([]*Timeline, 0)
 	// slot
 	sCount := readInt(reader)     // slotTimeline
@@ -551,19 +666,31 @@ func parseAnimation(reader io.Reader, strings []string, slots []*Slot, skin *Ski
 			timelines = append(timelines, temp)
 		}
 	}
-	// IK constraint skip
+	// IK constraint
 	count := readInt(reader)
 	for i := 0; i < count; i++ {
-		index := readInt(reader)
+		timeline := &Timeline{
+			Type:         TimelineIkConstraint,
+			IkConstraint: readInt(reader),
+		}
 		fCount := readInt(reader)
 		for j := 0; j < fCount; j++ {
-			other := readByte(reader, 3*4+3)
+			keyFrame := &KeyFrame{
+				Time: readF4(reader),
+				Mix:  readF4(reader),
+			}
+			if reader.Version.AtLeast(3, 8) { // 3.8 新增
+				keyFrame.Softness = readF4(reader)
+			}
+			keyFrame.BendDirection = int(int8(readU8(reader)))
+			keyFrame.Compress = readBool(reader)
+			keyFrame.Stretch = readBool(reader)
 			if j < fCount-1 {
-				readCurve(reader)
+				keyFrame.Curve = readCurve(reader)
 			}
-			Use(other)
+			timeline.KeyFrames = append(timeline.KeyFrames, keyFrame)
 		}
-		Use(index, fCount)
+		timelines = append(timelines, timeline)
 	}
 	// Transform constraint
 	count = readInt(reader)
@@ -642,16 +769,14 @@ func parseAnimation(reader io.Reader, strings []string, slots []*Slot, skin *Ski
 		}
 	}
 	// Deform
-	attachments := make(map[string]*Attachment)
-	for _, attachment := range skin.Attachments {
-		if attachment.Type == AttachmentMesh || attachment.Type == AttachmentPath || attachment.Type == AttachmentClip {
-			attachments[AttachmentKey(attachment.Name, attachment.Slot)] = attachment
-		}
-	}
 	count = readInt(reader)
 	for i := 0; i < count; i++ { // 按 skin 分组
-		if readInt(reader) != 0 { // 只支持默认皮肤
-			panic(fmt.Errorf("invalid skin: %v", skin))
+		skin := readInt(reader) + skinIndexOffset(skins)
+		attachments := make(map[string]*Attachment)
+		for _, attachment := range skins[skin].Attachments {
+			if attachment.Type != AttachmentRegion && attachment.Type != AttachmentPoint { // 有顶点的才能变形
+				attachments[AttachmentKey(attachment.Name, attachment.Slot)] = attachment
+			}
 		}
 		sCount = readInt(reader)
 		for j := 0; j < sCount; j++ { // 按 slot 分组
@@ -661,6 +786,7 @@ func parseAnimation(reader io.Reader, strings []string, slots []*Slot, skin *Ski
 				temp := &Timeline{
 					Type:       TimelineDeform,
 					Slot:       slot,
+					Skin:       skin,
 					Attachment: readRefStr(reader, strings),
 				}
 				key := AttachmentKey(temp.Attachment, temp.Slot)
@@ -668,40 +794,18 @@ func parseAnimation(reader io.Reader, strings []string, slots []*Slot, skin *Ski
 				if attachment == nil {
 					panic(fmt.Errorf("not find attachment %s", key))
 				}
-				size := 0
-				if attachment.Weight {
-					for _, items := range attachment.WeightVertices {
-						size += len(items)
-					}
-				} else {
-					size = len(attachment.Vertices)
-				}
 				fCount := readInt(reader)
 				for m := 0; m < fCount; m++ { // 每个 timeline 多帧动画
-					keyFrame := &KeyFrame{
-						Time:   readF4(reader),
-						Weight: attachment.Weight,
-					}
-					deform := make([]mgl32.Vec2, size)
-					cCount := readInt(reader)
-					if cCount > 0 {
-						start := readInt(reader)
-						end := start + cCount
-						for n := start; n < end; n++ {
-							deform[n/2][n%2] = readF4(reader)
+					time := readF4(reader)
+					start := 0
+					values := make([]float32, 0)
+					if cCount := readInt(reader); cCount > 0 {
+						start = readInt(reader)
+						for n := 0; n < cCount; n++ {
+							values = append(values, readF4(reader))
 						}
 					}
-					if attachment.Weight {
-						weightDeform := make([][]mgl32.Vec2, 0)
-						idx := 0
-						for _, items := range attachment.WeightVertices {
-							weightDeform = append(weightDeform, deform[idx:idx+len(items)])
-							idx += len(items)
-						}
-						keyFrame.WeightDeform = weightDeform
-					} else {
-						keyFrame.Deform = deform
-					}
+					keyFrame := newDeformKeyFrame(attachment, time, start, values)
 					if m < fCount-1 {
 						keyFrame.Curve = readCurve(reader)
 					}
@@ -711,8 +815,13 @@ func parseAnimation(reader io.Reader, strings []string, slots []*Slot, skin *Ski
 			}
 		}
 	}
+	return newAnimation(name, parseDrawOrderAndEvents(reader, timelines, slots, events))
+}
+
+// 各版本的 Draw order 与 Event 格式相同，都在动画最后
+func parseDrawOrderAndEvents(reader *SkelReader, timelines []*Timeline, slots []*Slot, events []*EventData) []*Timeline {
 	// Draw order
-	count = readInt(reader)
+	count := readInt(reader)
 	if count > 0 {
 		temp := &Timeline{
 			Type: TimelineDrawOrder,
@@ -721,28 +830,11 @@ func parseAnimation(reader io.Reader, strings []string, slots []*Slot, skin *Ski
 		for i := 0; i < count; i++ {
 			time := readF4(reader)
 			cCount := readInt(reader)
-			drawOrder := make([]int, size)
-			for j := 0; j < size; j++ {
-				drawOrder[j] = -1 // 先全部初始化为 -1
-			}
-			has := make(map[int]bool)
-			for j := 0; j < cCount; j++ { // 先分配有偏移的，剩下的顺序分配
-				idx := readInt(reader)
-				offset := int(int32(readInt(reader))) // 保留负号
-				drawOrder[idx] = idx + offset
-				has[idx+offset] = true
-			}
-			freeIdx := 0
-			for idx := 0; idx < size; idx++ {
-				if drawOrder[idx] >= 0 {
-					continue // 已经分配了
-				}
-				for has[freeIdx] {
-					freeIdx++
-				}
-				drawOrder[idx] = freeIdx
-				has[freeIdx] = true
+			offsets := make([][2]int, 0)
+			for j := 0; j < cCount; j++ {
+				offsets = append(offsets, [2]int{readInt(reader), int(int32(readInt(reader)))}) // 保留负号
 			}
+			drawOrder := newDrawOrder(size, offsets)
 			temp.KeyFrames = append(temp.KeyFrames, &KeyFrame{
 				Time:      time,
 				DrawOrder: drawOrder,
@@ -750,21 +842,44 @@ func parseAnimation(reader io.Reader, strings []string, slots []*Slot, skin *Ski
 		}
 		timelines = append(timelines, temp)
 	}
-	// Event skip
+	// Event
 	count = readInt(reader)
-	for i := 0; i < count; i++ {
-		time := readF4(reader)
-		index := readInt(reader)
-		intValue := readInt(reader)
-		floatValue := readF4(reader)
-		if readBool(reader) {
-			readStr(reader)
+	if count > 0 {
+		temp := &Timeline{
+			Type: TimelineEvent,
+		}
+		for i := 0; i < count; i++ {
+			event := &Event{
+				Time:  readF4(reader),
+				Data:  events[readInt(reader)],
+				Int:   readZigZagInt(reader),
+				Float: readF4(reader),
+			}
+			event.String = event.Data.String
+			if readBool(reader) { // 有自己的字符串才覆盖
+				event.String = readStr(reader)
+			}
+			event.Volume = event.Data.Volume
+			event.Balance = event.Data.Balance
+			if len(event.Data.AudioPath) > 0 { // 有音频时关键帧会覆盖音量与声道平衡
+				event.Volume = readF4(reader)
+				event.Balance = readF4(reader)
+			}
+			temp.KeyFrames = append(temp.KeyFrames, &KeyFrame{
+				Time:  event.Time,
+				Event: event,
+			})
 		}
-		Use(time, index, intValue, floatValue)
+		timelines = append(timelines, temp)
 	}
+	return timelines
+}
+
+// 关键帧按时间稳定排序，同一时间的事件保持文件中的顺序，最后一帧的时间就是动画时长
+func newAnimation(name string, timelines []*Timeline) *Animation {
 	duration := float32(0)
 	for _, timeline := range timelines {
-		sort.Slice(timeline.KeyFrames, func(i, j int) bool {
+		sort.SliceStable(timeline.KeyFrames, func(i, j int) bool {
 			return timeline.KeyFrames[i].Time < timeline.KeyFrames[j].Time
 		})
 		duration = max(duration, timeline.KeyFrames[len(timeline.KeyFrames)-1].Time)
@@ -776,68 +891,221 @@ func parseAnimation(reader io.Reader, strings []string, slots []*Slot, skin *Ski
 	}
 }
 
-func readCurve(reader io.Reader) *Curve {
+// offsets 为 [插槽, 偏移]，先分配有偏移的，剩下的插槽按原顺序依次分配
+func newDrawOrder(size int, offsets [][2]int) []int {
+	drawOrder := make([]int, size)
+	for i := 0; i < size; i++ {
+		drawOrder[i] = -1 // 先全部初始化为 -1
+	}
+	has := make(map[int]bool)
+	for _, item := range offsets {
+		drawOrder[item[0]] = item[0] + item[1]
+		has[item[0]+item[1]] = true
+	}
+	freeIdx := 0
+	for idx := 0; idx < size; idx++ {
+		if drawOrder[idx] >= 0 {
+			continue // 已经分配了
+		}
+		for has[freeIdx] {
+			freeIdx++
+		}
+		drawOrder[idx] = freeIdx
+		has[freeIdx] = true
+	}
+	return drawOrder
+}
+
+// values 为从 start 开始展开的顶点偏移，Weight 的按每个骨骼分组
+func newDeformKeyFrame(attachment *Attachment, time float32, start int, values []float32) *KeyFrame {
+	size := 0
+	if attachment.Weight {
+		for _, items := range attachment.WeightVertices {
+			size += len(items)
+		}
+	} else {
+		size = len(attachment.Vertices)
+	}
+	res := &KeyFrame{
+		Time:   time,
+		Weight: attachment.Weight,
+	}
+	deform := make([]mgl32.Vec2, size)
+	for i, value := range values {
+		deform[(start+i)/2][(start+i)%2] = value
+	}
+	if attachment.Weight {
+		weightDeform := make([][]mgl32.Vec2, 0)
+		idx := 0
+		for _, items := range attachment.WeightVertices {
+			weightDeform = append(weightDeform, deform[idx:idx+len(items)])
+			idx += len(items)
+		}
+		res.WeightDeform = weightDeform
+	} else {
+		res.Deform = deform
+	}
+	return res
+}
+
+func readCurve(reader *SkelReader) *Curve {
 	res := &Curve{}
 	res.Type = readU8(reader)
 	switch res.Type {
 	case CurveLinear, CurveStepped:
 		return res
 	case CurveBezier:
-		readAny(reader, &res.Data)
+		readVec2s(reader, res.Data[:])
 		return res
 	default:
-		panic(fmt.Sprintf("unknown curve type: %v", res.Type))
+		panic(fmt.Errorf("unknown curve type: %v", res.Type))
 	}
 }
 
-func skipEvents(reader io.Reader, strings []string) {
+func parseEvents(reader *SkelReader, strings []string) []*EventData {
+	res := make([]*EventData, 0)
 	count := readInt(reader)
 	for i := 0; i < count; i++ {
-		name := readRefStr(reader, strings)
-		intValue := readInt(reader)
-		floatValue := readF4(reader)
-		stringValue := readStr(reader)
-		audioPath := readStr(reader)
-		if len(audioPath) > 0 {
-			volume := readF4(reader)
-			balance := readF4(reader)
-			Use(volume, balance)
+		temp := &EventData{
+			Name:   readRefStr(reader, strings),
+			Int:    readZigZagInt(reader),
+			Float:  readF4(reader),
+			String: readStr(reader),
 		}
-		Use(name, intValue, floatValue, stringValue, audioPath)
+		temp.AudioPath = readStr(reader)
+		if len(temp.AudioPath) > 0 {
+			temp.Volume = readF4(reader)
+			temp.Balance = readF4(reader)
+		}
+		res = append(res, temp)
+	}
+	return res
+}
+
+func parseSkins(reader *SkelReader, strings []string, nonessential bool) []*Skin {
+	// 默认皮肤没有名称与依赖，没有附件时也保留一个空的默认皮肤，文件中的皮肤索引不包含它，参考 skinIndexOffset
+	reader.Section = "skin:default"
+	res := []*Skin{parseSkinAttachments(reader, strings, &Skin{Name: "default"}, nonessential)}
+	count := readInt(reader)
+	for i := 0; i < count; i++ {
+		res = append(res, parseSkin(reader, strings, nonessential))
+	}
+	return res
+}
+
+// spine-libgdx SkeletonBinary 不把空的默认皮肤加入皮肤列表，时间线中的皮肤索引按不包含它计算
+// 这里 skins[0] 总是默认皮肤，默认皮肤为空时文件中的索引要加 1
+func skinIndexOffset(skins []*Skin) int {
+	if len(skins[0].Attachments) == 0 {
+		return 1
+	}
+	return 0
+}
+
+func parseSkin(reader *SkelReader, strings []string, nonessential bool) *Skin {
+	res := &Skin{Name: readRefStr(reader, strings)}
+	reader.Section = "skin:" + res.Name
+	if !reader.Version.AtLeast(3, 8) { // 3.7 的皮肤没有骨骼与约束
+		return parseSkinAttachments(reader, strings, res, nonessential)
 	}
+	res.Bones = readInts(reader)
+	res.IkConstraints = readInts(reader)
+	res.TransformConstraints = readInts(reader)
+	res.PathConstraints = readInts(reader)
+	return parseSkinAttachments(reader, strings, res, nonessential)
 }
 
-func parseSkin(reader io.Reader, strings []string) *Skin {
-	attachments := make([]*Attachment, 0)
+func parseSkinAttachments(reader *SkelReader, strings []string, skin *Skin, nonessential bool) *Skin {
+	skin.Attachments = make([]*Attachment, 0)
 	slotCount := readInt(reader)
 	for i := 0; i < slotCount; i++ {
 		slot := readInt(reader)
 		attachmentCount := readInt(reader)
 		for j := 0; j < attachmentCount; j++ {
-			if temp := parseAttachment(reader, slot, strings); temp != nil {
-				attachments = append(attachments, temp)
+			if temp := parseAttachment(reader, slot, strings, nonessential); temp != nil {
+				skin.Attachments = append(skin.Attachments, temp)
+			}
+		}
+	}
+	return skin
+}
+
+// 参考 spine-libgdx 3.8 SkeletonBinary，链接网格引用的父网格可能在后面的皮肤中，所有皮肤解析完后再链接
+func linkMeshes(skins []*Skin) {
+	for _, skin := range skins {
+		for _, item := range skin.Attachments {
+			if item.Type == AttachmentLinkMesh {
+				linkMesh(skins, item)
+			}
+		}
+	}
+}
+
+func linkMesh(skins []*Skin, mesh *Attachment) {
+	if mesh.Parent != nil {
+		return // 作为其他链接网格的父网格时已经链接过了
+	}
+	skin := skins[0]
+	if len(mesh.ParentSkin) > 0 {
+		skin = nil
+		for _, item := range skins {
+			if item.Name == mesh.ParentSkin {
+				skin = item
 			}
 		}
+		if skin == nil {
+			panic(fmt.Errorf("not find skin %s", mesh.ParentSkin))
+		}
+	}
+	parent := skin.GetAttachment(mesh.Slot, mesh.ParentName)
+	if parent == nil || (parent.Type != AttachmentMesh && parent.Type != AttachmentLinkMesh) {
+		panic(fmt.Errorf("not find parent mesh %s", AttachmentKey(mesh.ParentName, mesh.Slot)))
+	}
+	if parent.Type == AttachmentLinkMesh {
+		linkMesh(skins, parent)
 	}
-	if readInt(reader) > 0 {
-		panic("not implemented more skin")
+	mesh.Parent = parent
+	mesh.Weight = parent.Weight
+	mesh.Vertices = parent.Vertices
+	mesh.WeightVertices = parent.WeightVertices
+	mesh.UVs = parent.UVs
+	mesh.Indices = parent.Indices
+	mesh.HullLength = parent.HullLength
+	mesh.Edges = parent.Edges
+	mesh.Size = parent.Size
+}
+
+// 变形动画作用的附件，继承变形的链接网格使用父网格的变形动画
+func (a *Attachment) GetDeformAttachment() *Attachment {
+	if a.Parent != nil && a.InheritDeform {
+		return a.Parent
 	}
-	return &Skin{
-		Attachments: attachments,
+	return a
+}
+
+// 先读数量再读对应数量的 int
+func readInts(reader *SkelReader) []int {
+	count := readInt(reader)
+	res := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		res = append(res, readInt(reader))
 	}
+	return res
 }
 
-func parseAttachment(reader io.Reader, slot int, strings []string) *Attachment {
-	defaultName := readRefStr(reader, strings)
-	name := readRefStr(reader, strings)
+func parseAttachment(reader *SkelReader, slot int, strings []string, nonessential bool) *Attachment {
+	placeholder := readRefStr(reader, strings)
+	realName := readRefStr(reader, strings)
+	name := realName
 	if len(name) == 0 {
-		name = defaultName
+		name = placeholder
 	}
 	attachmentType := readU8(reader)
 	res := &Attachment{
-		Name: name,
-		Slot: slot,
-		Type: attachmentType,
+		Name:     placeholder,
+		RealName: realName,
+		Slot:     slot,
+		Type:     attachmentType,
 	}
 	switch attachmentType {
 	case AttachmentRegion:
@@ -847,11 +1115,12 @@ func parseAttachment(reader io.Reader, slot int, strings []string) *Attachment {
 		}
 		res.Rotate = readF4(reader)
 		temp := [3]mgl32.Vec2{}
-		readAny(reader, &temp)
+		readVec2s(reader, temp[:])
 		res.Pos = temp[0]
 		res.Scale = temp[1]
 		res.Size = temp[2]
 		res.Color = readClr(reader)
+		readSequence(reader, res)
 	case AttachmentMesh:
 		res.Path = readRefStr(reader, strings)
 		if len(res.Path) == 0 {
@@ -865,13 +1134,36 @@ func parseAttachment(reader io.Reader, slot int, strings []string) *Attachment {
 				readF4(reader),
 			})
 		}
-		iCount := readInt(reader)
-		for i := 0; i < iCount; i++ {
-			res.Indices = append(res.Indices, readU16(reader))
-		}
+		res.Indices = readU16s(reader)
 		res.Weight = readBool(reader)
 		res.Vertices, res.WeightVertices = parseVertices(reader, vCount, res.Weight)
 		res.HullLength = readInt(reader)
+		readSequence(reader, res)
+		if nonessential {
+			res.Edges = readU16s(reader)
+			res.Size = mgl32.Vec2{readF4(reader), readF4(reader)}
+		}
+	case AttachmentLinkMesh:
+		res.Path = readRefStr(reader, strings)
+		if len(res.Path) == 0 {
+			res.Path = name
+		}
+		res.Color = readClr(reader)
+		res.ParentSkin = readRefStr(reader, strings)
+		res.ParentName = readRefStr(reader, strings)
+		res.InheritDeform = readBool(reader)
+		readSequence(reader, res)
+		if nonessential {
+			res.Size = mgl32.Vec2{readF4(reader), readF4(reader)}
+		}
+	case AttachmentBoundBox:
+		count := readInt(reader)
+		res.Weight = readBool(reader)
+		res.Vertices, res.WeightVertices = parseVertices(reader, count, res.Weight)
+		if nonessential {
+			res.Color = readClr(reader)
+		}
+		return res
 	case AttachmentPath: // 生成运动路径 依赖 Path constraints 与 路径动画 生效
 		res.Close = readBool(reader)
 		res.ConstantSpeed = readBool(reader)
@@ -881,20 +1173,47 @@ func parseAttachment(reader io.Reader, slot int, strings []string) *Attachment {
 		for i := 0; i < count/3; i++ {
 			res.Lengths = append(res.Lengths, readF4(reader))
 		}
+		if nonessential {
+			res.Color = readClr(reader)
+		}
+		return res
+	case AttachmentPoint:
+		res.Rotate = readF4(reader)
+		res.Pos = mgl32.Vec2{readF4(reader), readF4(reader)}
+		if nonessential {
+			res.Color = readClr(reader)
+		}
 		return res
 	case AttachmentClip:
 		res.EndSlot = readInt(reader)
 		count := readInt(reader)
 		res.Weight = readBool(reader)
 		res.Vertices, res.WeightVertices = parseVertices(reader, count, res.Weight)
+		if nonessential {
+			res.Color = readClr(reader)
+		}
 		return res
 	default:
-		panic(fmt.Sprintf("unknown attachment type: %v", attachmentType))
+		panic(fmt.Errorf("unknown attachment type: %v", attachmentType))
 	}
 	return res
 }
 
-func parseVertices(reader io.Reader, count int, weight bool) ([]mgl32.Vec2, [][]*WeightVertex) {
+// 4.1 新增，有序列帧时换成初始帧的图片
+func readSequence(reader *SkelReader, attachment *Attachment) {
+	if !reader.Version.AtLeast(4, 1) || !readBool(reader) {
+		return
+	}
+	attachment.Sequence = &Sequence{
+		Count:      readInt(reader),
+		Start:      readInt(reader),
+		Digits:     readInt(reader),
+		SetupIndex: readInt(reader),
+	}
+	attachment.Path = attachment.Sequence.GetPath(attachment.Path, attachment.Sequence.SetupIndex)
+}
+
+func parseVertices(reader *SkelReader, count int, weight bool) ([]mgl32.Vec2, [][]*WeightVertex) {
 	vertices := make([]mgl32.Vec2, 0)
 	weightVertices := make([][]*WeightVertex, 0)
 	for i := 0; i < count; i++ {
@@ -919,29 +1238,48 @@ func parseVertices(reader io.Reader, count int, weight bool) ([]mgl32.Vec2, [][]
 	return vertices, weightVertices
 }
 
-func readU16(reader io.Reader) uint16 {
-	temp := readByte(reader, 2)
-	return binary.BigEndian.Uint16(temp)
+// 先读数量再读对应数量的 uint16
+func readU16s(reader *SkelReader) []uint16 {
+	count := readInt(reader)
+	res := make([]uint16, 0, count)
+	for i := 0; i < count; i++ {
+		res = append(res, readU16(reader))
+	}
+	return res
+}
+
+func readU16(reader *SkelReader) uint16 {
+	return binary.BigEndian.Uint16(reader.next(2))
 }
 
-func skipIKConstraints(reader io.Reader) {
+func parseIkConstraints(reader *SkelReader) []*IkConstraint {
+	res := make([]*IkConstraint, 0)
 	count := readInt(reader)
 	for i := 0; i < count; i++ {
-		name := readStr(reader)
-		order := readInt(reader)
-		skinRequire := readBool(reader)
+		temp := &IkConstraint{
+			Name:        readStr(reader),
+			Order:       readInt(reader),
+			SkinRequire: readSkinRequire(reader),
+		}
 		boneCount := readInt(reader)
 		for j := 0; j < boneCount; j++ {
-			bone := readInt(reader)
-			Use(bone)
+			temp.Bones = append(temp.Bones, readInt(reader))
 		}
-		target := readInt(reader)
-		other := readByte(reader, 4*2+4)
-		Use(name, order, skinRequire, target, other)
+		temp.Target = readInt(reader)
+		temp.Mix = readF4(reader)
+		if reader.Version.AtLeast(3, 8) { // 3.8 新增
+			temp.Softness = readF4(reader)
+		}
+		temp.BendDirection = int(int8(readU8(reader))) // 保留负号
+		temp.Compress = readBool(reader)
+		temp.Stretch = readBool(reader)
+		temp.Uniform = readBool(reader)
+		res = append(res, temp)
 	}
+	return res
 }
 
-func parseSlots(reader io.Reader, strings []string) []*Slot {
+func parseSlots(reader *SkelReader, strings []string) []*Slot {
 	res := make([]*Slot, 0)
 	count := readInt(reader)
 	for i := 0; i < count; i++ {
@@ -952,7 +1290,7 @@ func parseSlots(reader io.Reader, strings []string) []*Slot {
 	return res
 }
 
-func parseSlot(reader io.Reader, strings []string) *Slot {
+func parseSlot(reader *SkelReader, strings []string) *Slot {
 	name := readStr(reader)
 	bone := readInt(reader)
 	color := readClr(reader)
@@ -969,7 +1307,10 @@ func parseSlot(reader io.Reader, strings []string) *Slot {
 	}
 }
 
-func readRefStr(reader io.Reader, strings []string) string {
+func readRefStr(reader *SkelReader, strings []string) string {
+	if !reader.Version.AtLeast(3, 8) {
+		return readStr(reader)
+	}
 	idx := readInt(reader) - 1
 	if idx < 0 {
 		return ""
@@ -977,8 +1318,8 @@ func readRefStr(reader io.Reader, strings []string) string {
 	return strings[idx]
 }
 
-func readClr(reader io.Reader) mgl32.Vec4 {
-	data := readByte(reader, 4)
+func readClr(reader *SkelReader) mgl32.Vec4 {
+	data := reader.next(4)
 	return mgl32.Vec4{
 		float32(data[0]) / 0xFF,
 		float32(data[1]) / 0xFF,
@@ -987,16 +1328,16 @@ func readClr(reader io.Reader) mgl32.Vec4 {
 	}
 }
 
-func parseBones(reader io.Reader) []*Bone {
+func parseBones(reader *SkelReader, nonessential bool) []*Bone {
 	count := readInt(reader)
 	res := make([]*Bone, 0)
 	for i := 0; i < count; i++ {
-		res = append(res, parseBone(reader, i == 0))
+		res = append(res, parseBone(reader, i == 0, nonessential))
 	}
 	return res
 }
 
-func parseBone(reader io.Reader, first bool) *Bone {
+func parseBone(reader *SkelReader, first bool, nonessential bool) *Bone {
 	name := readStr(reader)
 	parent := -1
 	if !first {
@@ -1004,10 +1345,14 @@ func parseBone(reader io.Reader, first bool) *Bone {
 	}
 	rotate := readF4(reader)
 	temp := [3]mgl32.Vec2{}
-	readAny(reader, &temp)
+	readVec2s(reader, temp[:])
 	length := readF4(reader)
 	mode := readU8(reader)
-	skip := readBool(reader)
+	skip := reader.Version.AtLeast(3, 8) && readBool(reader) // 3.7 没有 SkinRequire
+	color := mgl32.Vec4{}
+	if nonessential {
+		color = readClr(reader)
+	}
 	return &Bone{
 		Name:          name,
 		Parent:        parent,
@@ -1018,59 +1363,115 @@ func parseBone(reader io.Reader, first bool) *Bone {
 		Length:        length,
 		TransformMode: mode,
 		SkinRequire:   skip,
+		Color:         color,
 	}
 }
 
-func readF4(reader io.Reader) float32 {
-	bs := readByte(reader, 4)
-	temp := binary.BigEndian.Uint32(bs)
-	return math.Float32frombits(temp)
+func readF4(reader *SkelReader) float32 {
+	return math.Float32frombits(binary.BigEndian.Uint32(reader.next(4)))
 }
 
-func parseStrings(reader io.Reader) []string {
-	count := readInt(reader)
+func parseStrings(reader *SkelReader) []string {
 	res := make([]string, 0)
+	if !reader.Version.AtLeast(3, 8) { // 3.7 没有字符串表，引用字符串直接写在原处
+		return res
+	}
+	count := readInt(reader)
 	for i := 0; i < count; i++ {
 		res = append(res, readStr(reader))
 	}
 	return res
 }
 
-func parseSkelHeader(reader io.Reader) *SkelHeader {
-	hash := readStr(reader)
-	version := readStr(reader)
-	temp := [2]mgl32.Vec2{}
-	readAny(reader, &temp)
-	if readBool(reader) { // 可有可无的数据，就不要导出了
-		panic("not support nonessential")
+func parseSkelHeader(reader *SkelReader) *SkelHeader {
+	version := peekVersion(reader)
+	temp, ok := parseVersion(version)
+	if !ok || !slices.Contains(SupportedVersions, temp) {
+		panic(fmt.Errorf("unsupported spine version %q, only 3.7 3.8 4.0 4.1 are supported", version))
+	}
+	reader.Version = temp
+	res := &SkelHeader{}
+	if reader.Version.AtLeast(4, 0) { // 4.x 的 hash 为 8 字节整数
+		if hash := int64(binary.BigEndian.Uint64(reader.next(8))); hash != 0 {
+			res.Hash = strconv.FormatInt(hash, 10)
+		}
+	} else {
+		res.Hash = readStr(reader)
+	}
+	res.Version = readStr(reader)
+	if reader.Version.AtLeast(3, 8) { // 3.7 没有 x y
+		res.Pos = mgl32.Vec2{readF4(reader), readF4(reader)}
+	}
+	res.Size = mgl32.Vec2{readF4(reader), readF4(reader)}
+	res.Nonessential = readBool(reader)
+	if res.Nonessential { // 可有可无的数据，编辑器使用
+		res.Fps = readF4(reader)
+		res.ImagesPath = readStr(reader)
+		res.AudioPath = readStr(reader)
+	}
+	return res
+}
+
+// 3.x 的 hash 为字符串，4.x 为 8 字节整数，分别按两种格式预读版本号，都不像版本号时返回空
+func peekVersion(reader *SkelReader) string {
+	bs, _ := reader.Reader.Peek(256)
+	if _, end := peekStr(bs, 0); end > 0 {
+		if version, _ := peekStr(bs, end); len(version) > 2 && version[:2] == "3." {
+			return version
+		}
 	}
-	return &SkelHeader{
-		Hash:    hash,
-		Version: version,
-		Pos:     temp[0],
-		Size:    temp[1],
+	if version, _ := peekStr(bs, 8); len(version) > 2 && version[:2] == "4." {
+		return version
 	}
+	return ""
 }
 
-func readBool(reader io.Reader) bool {
+// 与 readStr 相同，数据不足时 end 为 -1，只用于预读 ASCII 的版本号
+func peekStr(bs []byte, start int) (string, int) {
+	count, shift := 0, 0
+	for i := start; i < len(bs) && shift < 35; i++ {
+		count |= int(bs[i]&127) << shift
+		shift += 7
+		if bs[i]&128 == 0 {
+			end := i + max(count, 1)
+			if end > len(bs) {
+				return "", -1
+			}
+			return string(bs[i+1 : end]), end
+		}
+	}
+	return "", -1
+}
+
+// 3.8 新增，3.7 的都为 false
+func readSkinRequire(reader *SkelReader) bool {
+	return reader.Version.AtLeast(3, 8) && readBool(reader)
+}
+
+func readBool(reader *SkelReader) bool {
 	return readU8(reader) == 1
 }
 
-func readAny(reader io.Reader, desc any) {
-	err := binary.Read(reader, binary.BigEndian, desc)
-	HandleErr(err)
+func readVec2s(reader *SkelReader, res []mgl32.Vec2) {
+	bs := reader.next(len(res) * 8)
+	for i := range res {
+		res[i] = mgl32.Vec2{
+			math.Float32frombits(binary.BigEndian.Uint32(bs[i*8:])),
+			math.Float32frombits(binary.BigEndian.Uint32(bs[i*8+4:])),
+		}
+	}
 }
 
-func readStr(reader io.Reader) string {
-	count := readInt(reader)
+// 长度为字符数 + 1，0 为 null，非 ASCII 字符按 utf8 占多个字节
+func readStr(reader *SkelReader) string {
+	count := readInt(reader) // utf8 字节数 +1
 	if count <= 1 {
 		return ""
 	}
-	temp := readByte(reader, count-1)
-	return string(temp)
+	return string(reader.next(count - 1))
 }
 
-func readInt(reader io.Reader) int {
+func readInt(reader *SkelReader) int {
 	temp := readU8(reader)
 	res := int(temp & 127)
 	if (temp & 128) != 0 {
@@ -1093,13 +1494,12 @@ func readInt(reader io.Reader) int {
 	return res
 }
 
-func readU8(reader io.Reader) uint8 {
-	return readByte(reader, 1)[0]
+// 参考 spine-libgdx 3.8 SkeletonBinary.readInt(false)，zigzag 编码，绝对值小的负数也只占少量字节
+func readZigZagInt(reader *SkelReader) int {
+	temp := readInt(reader)
+	return (temp >> 1) ^ -(temp & 1)
 }
 
-func readByte(reader io.Reader, count int) []byte {
-	res := make([]byte, count)
-	_, err := reader.Read(res)
-	HandleErr(err)
-	return res
+func readU8(reader *SkelReader) uint8 {
+	return reader.next(1)[0]
 }
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"sort"
+)
+
+// 4.x 的动画每个分量都有自己的曲线，x y、rgb alpha 还可以拆成单独的时间线
+// 解析后转换为 3.8 的时间线，每帧只保留一条曲线，拆开的时间线按所有关键帧的时间重新采样后合并
+// 有变化的分量曲线不同，或者重新采样会改变贝塞尔曲线时 3.8 的数据无法表示，解析报错
+
+const ( // 4.x 插槽时间线
+	Slot4Attachment = 0
+	Slot4Rgba       = 1
+	Slot4Rgb        = 2
+	Slot4Rgba2      = 3
+	Slot4Rgb2       = 4
+	Slot4Alpha      = 5
+)
+
+const ( // 4.x 骨骼时间线
+	Bone4Rotate     = 0
+	Bone4Translate  = 1
+	Bone4TranslateX = 2
+	Bone4TranslateY = 3
+	Bone4Scale      = 4
+	Bone4ScaleX     = 5
+	Bone4ScaleY     = 6
+	Bone4Shear      = 7
+	Bone4ShearX     = 8
+	Bone4ShearY     = 9
+)
+
+const ( // 4.1 附件时间线，4.0 只有变形
+	Attachment4Deform   = 0
+	Attachment4Sequence = 1
+)
+
+// 4.x 的曲线时间线
+type curveTimeline struct {
+	KeyFrames []*KeyFrame // 每帧的关键帧，插值的数值转换时再写入
+	Values    [][]float32 // 每帧各分量的值
+	Curves    [][]*Curve  // 每帧到下一帧各分量的曲线，已按前后两帧归一化，最后一帧为 nil
+}
+
+// 有变化的分量的曲线，最后一帧为 nil
+func (c *curveTimeline) Curve(frame int) *Curve {
+	curves := c.Curves[frame]
+	if curves == nil {
+		return nil
+	}
+	var res *Curve
+	for i, curve := range curves {
+		if i >= len(c.Values[frame]) || c.Values[frame][i] == c.Values[frame+1][i] {
+			continue
+		}
+		if res == nil {
+			res = curve
+		} else if !curveEqual(res, curve) {
+			panic(fmt.Errorf("curves of frame %v differ between channels, separate channel curves are not supported", c.KeyFrames[frame].Time))
+		}
+	}
+	if res == nil {
+		return curves[0]
+	}
+	return res
+}
+
+// 各分量的贝塞尔曲线按自身的数值归一化，相同的曲线也会有误差
+func curveEqual(curve1, curve2 *Curve) bool {
+	if curve1.Type != curve2.Type {
+		return false
+	}
+	for i := range curve1.Data {
+		if !curve1.Data[i].ApproxEqualThreshold(curve2.Data[i], 1e-3) {
+			return false
+		}
+	}
+	return true
+}
+
+// 与 AnimUpdate 相同，第一帧之前使用第一帧的值
+func (c *curveTimeline) Value(time float32, channel int) float32 {
+	idx := c.index(time)
+	if idx < 0 {
+		return c.Values[0][channel]
+	}
+	if idx+1 >= len(c.KeyFrames) {
+		return c.Values[idx][channel]
+	}
+	pre, next := c.KeyFrames[idx].Time, c.KeyFrames[idx+1].Time
+	rate := CurveVal(c.Curves[idx][channel], (time-pre)/(next-pre))
+	return Lerp(c.Values[idx][channel], c.Values[idx+1][channel], rate)
+}
+
+// 时间不大于 time 的最后一帧
+func (c *curveTimeline) index(time float32) int {
+	return sort.Search(len(c.KeyFrames), func(i int) bool {
+		return c.KeyFrames[i].Time > time
+	}) - 1
+}
+
+// 转换为 3.8 的关键帧，set 写入插值的数值
+func (c *curveTimeline) ToKeyFrames(set func(keyFrame *KeyFrame, values []float32)) []*KeyFrame {
+	for i, keyFrame := range c.KeyFrames {
+		keyFrame.Curve = c.Curve(i)
+		set(keyFrame, c.Values[i])
+	}
+	return c.KeyFrames
+}
+
+// 需要合并的时间线，Channels 为每个时间线的分量在合并后的位置
+type curveGroup struct {
+	Timelines []*curveTimeline
+	Channels  [][]int
+}
+
+func (g *curveGroup) Add(timeline *curveTimeline, channels ...int) {
+	g.Timelines = append(g.Timelines, timeline)
+	g.Channels = append(g.Channels, channels)
+}
+
+// 按所有关键帧的时间重新采样，没有时间线的分量使用 def
+// 两帧之间与原时间线的两帧相同的分量保留原曲线，阶梯曲线直接保留，线性曲线重新采样后不变
+// 贝塞尔曲线被拆开时无法用一条曲线表示，解析报错
+func (g *curveGroup) Merge(def []float32) *curveTimeline {
+	times := make([]float32, 0)
+	for _, timeline := range g.Timelines {
+		for _, keyFrame := range timeline.KeyFrames {
+			times = append(times, keyFrame.Time)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i] < times[j]
+	})
+	res := &curveTimeline{}
+	for i, time := range times {
+		if i > 0 && time == times[i-1] {
+			continue
+		}
+		res.KeyFrames = append(res.KeyFrames, &KeyFrame{Time: time})
+	}
+	for i, keyFrame := range res.KeyFrames {
+		values := append([]float32(nil), def...)
+		var curves []*Curve
+		if i+1 < len(res.KeyFrames) {
+			curves = make([]*Curve, len(def))
+			for j := range curves {
+				curves[j] = &Curve{Type: CurveLinear}
+			}
+		}
+		for j, timeline := range g.Timelines {
+			idx := timeline.index(keyFrame.Time)
+			for k, channel := range g.Channels[j] {
+				values[channel] = timeline.Value(keyFrame.Time, k)
+				if curves == nil || idx < 0 || idx+1 >= len(timeline.KeyFrames) {
+					continue
+				}
+				curve := timeline.Curves[idx][k]
+				if curve.Type == CurveStepped || timeline.KeyFrames[idx].Time == keyFrame.Time && timeline.KeyFrames[idx+1].Time == res.KeyFrames[i+1].Time {
+					curves[channel] = curve
+				} else if curve.Type == CurveBezier && timeline.Values[idx][k] != timeline.Values[idx+1][k] {
+					panic(fmt.Errorf("bezier curve of frame %v is split by other channels, separate channel curves are not supported", timeline.KeyFrames[idx].Time))
+				}
+			}
+		}
+		res.Values = append(res.Values, values)
+		res.Curves = append(res.Curves, curves)
+	}
+	return res
+}
+
+// 参考 spine-libgdx 4.0 SkeletonBinary.readTimeline，先读取贝塞尔曲线数量
+// 每帧依次为时间、channels 个插值的数值、上一帧到这一帧的曲线、newKeyFrame 读取的不插值的数据
+func readCurveTimeline(reader *SkelReader, count int, channels int, readValue func(reader *SkelReader) float32, newKeyFrame func() *KeyFrame) *curveTimeline {
+	readInt(reader) // 贝塞尔曲线数量，用于预分配
+	res := &curveTimeline{}
+	for i := 0; i < count; i++ {
+		time := readF4(reader)
+		values := make([]float32, channels)
+		for j := range values {
+			values[j] = readValue(reader)
+		}
+		if i > 0 {
+			res.Curves[i-1] = readCurve4(reader, res.KeyFrames[i-1].Time, time, res.Values[i-1], values)
+		}
+		keyFrame := &KeyFrame{}
+		if newKeyFrame != nil {
+			keyFrame = newKeyFrame()
+		}
+		keyFrame.Time = time
+		res.KeyFrames = append(res.KeyFrames, keyFrame)
+		res.Values = append(res.Values, values)
+		res.Curves = append(res.Curves, nil)
+	}
+	return res
+}
+
+// 贝塞尔曲线每个分量 4 个数，控制点为实际的时间与数值，归一化到 0~1，没有插值数值的按 0~1
+func readCurve4(reader *SkelReader, time1, time2 float32, values1, values2 []float32) []*Curve {
+	res := make([]*Curve, max(len(values1), 1))
+	type0 := readU8(reader)
+	for i := range res {
+		res[i] = &Curve{Type: type0}
+		switch type0 {
+		case CurveLinear, CurveStepped:
+		case CurveBezier:
+			value1, value2 := float32(0), float32(1)
+			if len(values1) > 0 {
+				value1, value2 = values1[i], values2[i]
+			}
+			for j := range res[i].Data {
+				x := (readF4(reader) - time1) / (time2 - time1)
+				y := x // 数值不变时曲线没有影响
+				if value := readF4(reader); value1 != value2 {
+					y = (value - value1) / (value2 - value1)
+				}
+				res[i].Data[j] = mgl32.Vec2{x, y}
+			}
+		default:
+			panic(fmt.Errorf("unknown curve type: %v", type0))
+		}
+	}
+	return res
+}
+
+func readClrByte(reader *SkelReader) float32 {
+	return float32(readU8(reader)) / 0xFF
+}
+
+// 参考 spine-libgdx 4.0 与 4.1 SkeletonBinary.readAnimation
+func parseAnimation4(reader *SkelReader, strings []string, slots []*Slot, skins []*Skin, events []*EventData) *Animation {
+	name := readStr(reader)
+	reader.Section = "animation:" + name
+	readInt(reader) // 时间线数量，用于预分配
+	timelines := make([]*Timeline, 0)
+	// slot
+	sCount := readInt(reader)
+	for i := 0; i < sCount; i++ {
+		slot := slots[readInt(reader)]
+		colors := &curveGroup{} // 颜色分量按 r g b a r2 g2 b2 合并
+		tCount := readInt(reader)
+		for j := 0; j < tCount; j++ {
+			type0 := readU8(reader)
+			fCount := readInt(reader)
+			switch type0 {
+			case Slot4Attachment:
+				temp := &Timeline{
+					Type: TimelineAttachment,
+					Slot: slot.Index,
+				}
+				for k := 0; k < fCount; k++ {
+					temp.KeyFrames = append(temp.KeyFrames, &KeyFrame{
+						Time:       readF4(reader),
+						Attachment: readRefStr(reader, strings),
+					})
+				}
+				timelines = append(timelines, temp)
+			case Slot4Rgba:
+				colors.Add(readCurveTimeline(reader, fCount, 4, readClrByte, nil), 0, 1, 2, 3)
+			case Slot4Rgb:
+				colors.Add(readCurveTimeline(reader, fCount, 3, readClrByte, nil), 0, 1, 2)
+			case Slot4Rgba2:
+				colors.Add(readCurveTimeline(reader, fCount, 7, readClrByte, nil), 0, 1, 2, 3, 4, 5, 6)
+			case Slot4Rgb2:
+				colors.Add(readCurveTimeline(reader, fCount, 6, readClrByte, nil), 0, 1, 2, 4, 5, 6)
+			case Slot4Alpha:
+				colors.Add(readCurveTimeline(reader, fCount, 1, readClrByte, nil), 3)
+			default:
+				panic(fmt.Errorf("unknown slot type: %v", type0))
+			}
+		}
+		if len(colors.Timelines) > 0 {
+			timelines = append(timelines, newColorTimeline(slot, colors))
+		}
+	}
+	// bone
+	bCount := readInt(reader)
+	for i := 0; i < bCount; i++ {
+		bone := readInt(reader)
+		groups := make(map[uint8]*curveGroup) // 按 3.8 的时间线类型合并 x y
+		types := make([]uint8, 0)
+		add := func(type0 uint8, timeline *curveTimeline, channels ...int) {
+			if groups[type0] == nil {
+				groups[type0] = &curveGroup{}
+				types = append(types, type0)
+			}
+			groups[type0].Add(timeline, channels...)
+		}
+		tCount := readInt(reader)
+		for j := 0; j < tCount; j++ {
+			type0 := readU8(reader)
+			fCount := readInt(reader)
+			switch type0 {
+			case Bone4Rotate:
+				timelines = append(timelines, &Timeline{
+					Type: TimelineRotate,
+					Bone: bone,
+					KeyFrames: readCurveTimeline(reader, fCount, 1, readF4, nil).ToKeyFrames(func(keyFrame *KeyFrame, values []float32) {
+						keyFrame.Rotate = values[0]
+					}),
+				})
+			case Bone4Translate:
+				add(TimelineTranslate, readCurveTimeline(reader, fCount, 2, readF4, nil), 0, 1)
+			case Bone4TranslateX:
+				add(TimelineTranslate, readCurveTimeline(reader, fCount, 1, readF4, nil), 0)
+			case Bone4TranslateY:
+				add(TimelineTranslate, readCurveTimeline(reader, fCount, 1, readF4, nil), 1)
+			case Bone4Scale:
+				add(TimelineScale, readCurveTimeline(reader, fCount, 2, readF4, nil), 0, 1)
+			case Bone4ScaleX:
+				add(TimelineScale, readCurveTimeline(reader, fCount, 1, readF4, nil), 0)
+			case Bone4ScaleY:
+				add(TimelineScale, readCurveTimeline(reader, fCount, 1, readF4, nil), 1)
+			case Bone4Shear:
+				add(TimelineShear, readCurveTimeline(reader, fCount, 2, readF4, nil), 0, 1)
+			case Bone4ShearX:
+				add(TimelineShear, readCurveTimeline(reader, fCount, 1, readF4, nil), 0)
+			case Bone4ShearY:
+				add(TimelineShear, readCurveTimeline(reader, fCount, 1, readF4, nil), 1)
+			default:
+				panic(fmt.Errorf("unknown bone type: %v", type0))
+			}
+		}
+		for _, type0 := range types {
+			def := []float32{0, 0}
+			if type0 == TimelineScale { // 缩放是乘以初始值
+				def = []float32{1, 1}
+			}
+			timelines = append(timelines, &Timeline{
+				Type: type0,
+				Bone: bone,
+				KeyFrames: groups[type0].Merge(def).ToKeyFrames(func(keyFrame *KeyFrame, values []float32) {
+					value := mgl32.Vec2{values[0], values[1]}
+					switch type0 {
+					case TimelineTranslate:
+						keyFrame.Offset = value
+					case TimelineScale:
+						keyFrame.Scale = value
+					case TimelineShear:
+						keyFrame.Shear = value
+					}
+				}),
+			})
+		}
+	}
+	// IK constraint
+	count := readInt(reader)
+	for i := 0; i < count; i++ {
+		timeline := &Timeline{
+			Type:         TimelineIkConstraint,
+			IkConstraint: readInt(reader),
+		}
+		fCount := readInt(reader)
+		timeline.KeyFrames = readCurveTimeline(reader, fCount, 2, readF4, func() *KeyFrame {
+			return &KeyFrame{
+				BendDirection: int(int8(readU8(reader))),
+				Compress:      readBool(reader),
+				Stretch:       readBool(reader),
+			}
+		}).ToKeyFrames(func(keyFrame *KeyFrame, values []float32) {
+			keyFrame.Mix = values[0]
+			keyFrame.Softness = values[1]
+		})
+		timelines = append(timelines, timeline)
+	}
+	// Transform constraint 每个分量有自己的 mix，依次为 rotate x y scaleX scaleY shearY
+	count = readInt(reader)
+	for i := 0; i < count; i++ {
+		timeline := &Timeline{
+			Type:                TimelineTransformConstraint,
+			TransformConstraint: readInt(reader),
+		}
+		fCount := readInt(reader)
+		timeline.KeyFrames = readCurveTimeline(reader, fCount, 6, readF4, nil).ToKeyFrames(func(keyFrame *KeyFrame, values []float32) {
+			checkMixXY("offset", values[1], values[2])
+			checkMixXY("scale", values[3], values[4])
+			keyFrame.RotateMix = values[0]
+			keyFrame.OffsetMix = values[1]
+			keyFrame.ScaleMix = values[3]
+			keyFrame.ShearMix = values[5]
+		})
+		timelines = append(timelines, timeline)
+	}
+	// Path constraint
+	count = readInt(reader)
+	for i := 0; i < count; i++ {
+		pathConstraint := readInt(reader)
+		tCount := readInt(reader)
+		for j := 0; j < tCount; j++ {
+			timeline := &Timeline{
+				PathConstraint: pathConstraint,
+			}
+			type0 := readU8(reader)
+			fCount := readInt(reader)
+			switch type0 {
+			case PathPosition:
+				timeline.Type = TimelinePathConstraintPosition
+				timeline.KeyFrames = readCurveTimeline(reader, fCount, 1, readF4, nil).ToKeyFrames(func(keyFrame *KeyFrame, values []float32) {
+					keyFrame.Position = values[0]
+				})
+			case PathSpace:
+				timeline.Type = TimelinePathConstraintSpace
+				timeline.KeyFrames = readCurveTimeline(reader, fCount, 1, readF4, nil).ToKeyFrames(func(keyFrame *KeyFrame, values []float32) {
+					keyFrame.Space = values[0]
+				})
+			case PathMix: // 平移的 mix 分为 x y
+				timeline.Type = TimelinePathConstraintMix
+				timeline.KeyFrames = readCurveTimeline(reader, fCount, 3, readF4, nil).ToKeyFrames(func(keyFrame *KeyFrame, values []float32) {
+					checkMixXY("offset", values[1], values[2])
+					keyFrame.RotateMix = values[0]
+					keyFrame.OffsetMix = values[1]
+				})
+			default:
+				panic(fmt.Errorf("unknown path type: %v", type0))
+			}
+			timelines = append(timelines, timeline)
+		}
+	}
+	// Deform，4.1 改为附件时间线，增加了序列帧
+	count = readInt(reader)
+	for i := 0; i < count; i++ { // 按 skin 分组
+		skin := readInt(reader) + skinIndexOffset(skins)
+		sCount = readInt(reader)
+		for j := 0; j < sCount; j++ { // 按 slot 分组
+			slot := readInt(reader)
+			aCount := readInt(reader)
+			for k := 0; k < aCount; k++ { // 按 attachment 分组
+				temp := &Timeline{
+					Type:       TimelineDeform,
+					Slot:       slot,
+					Skin:       skin,
+					Attachment: readRefStr(reader, strings),
+				}
+				attachment := skins[skin].GetAttachment(slot, temp.Attachment)
+				if attachment == nil {
+					panic(fmt.Errorf("not find attachment %s", AttachmentKey(temp.Attachment, slot)))
+				}
+				type0 := uint8(Attachment4Deform)
+				if reader.Version.AtLeast(4, 1) {
+					type0 = readU8(reader)
+				}
+				fCount := readInt(reader)
+				switch type0 {
+				case Attachment4Deform:
+					temp.KeyFrames = readCurveTimeline(reader, fCount, 0, readF4, func() *KeyFrame {
+						start := 0
+						values := make([]float32, 0)
+						if cCount := readInt(reader); cCount > 0 {
+							start = readInt(reader)
+							for n := 0; n < cCount; n++ {
+								values = append(values, readF4(reader))
+							}
+						}
+						return newDeformKeyFrame(attachment, 0, start, values)
+					}).ToKeyFrames(func(keyFrame *KeyFrame, values []float32) {})
+					timelines = append(timelines, temp)
+				case Attachment4Sequence: // 序列帧动画暂不支持，只显示初始帧
+					for m := 0; m < fCount; m++ {
+						readF4(reader) // 时间
+						reader.next(4) // 低 4 位为播放模式，其余为帧下标
+						readF4(reader) // 每帧间隔
+					}
+				default:
+					panic(fmt.Errorf("unknown attachment timeline type: %v", type0))
+				}
+			}
+		}
+	}
+	return newAnimation(name, parseDrawOrderAndEvents(reader, timelines, slots, events))
+}
+
+// 有暗色分量的转换为 TimelineTwoColor，缺少的分量使用插槽的初始颜色
+func newColorTimeline(slot *Slot, colors *curveGroup) *Timeline {
+	res := &Timeline{
+		Type: TimelineColor,
+		Slot: slot.Index,
+	}
+	for _, channels := range colors.Channels {
+		if channels[len(channels)-1] > 3 {
+			res.Type = TimelineTwoColor
+		}
+	}
+	def := []float32{slot.Color[0], slot.Color[1], slot.Color[2], slot.Color[3], slot.DarkColor[1], slot.DarkColor[2], slot.DarkColor[3]}
+	res.KeyFrames = colors.Merge(def).ToKeyFrames(func(keyFrame *KeyFrame, values []float32) {
+		keyFrame.Color = mgl32.Vec4{values[0], values[1], values[2], values[3]}
+		if res.Type == TimelineTwoColor {
+			keyFrame.DarkColor = mgl32.Vec4{0, values[4], values[5], values[6]} // 与插槽的暗色相同，第一个分量为 0
+		}
+	})
+	return res
+}
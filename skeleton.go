@@ -0,0 +1,272 @@
+package main
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// 骨骼动画实例，参考 spine-libgdx 3.8 Skeleton
+// SkeletonData 只读，可以被多个实例共享，骨骼、插槽、约束与变形等运行时数据都在实例上
+type Skeleton struct {
+	Data                 *SkeletonData
+	Bones                []*BoneNode // 与 Data.Bones 一一对应
+	Root                 *BoneNode
+	Slots                []*SlotState // 与 Data.Slots 一一对应
+	DrawOrder            []*SlotState // 按 CurrOrder 排好的绘制顺序
+	IkConstraints        []*IkConstraintState
+	TransformConstraints []*TransformConstraintState
+	PathConstraints      []*PathConstraintState
+	Deforms              map[*Attachment]*AttachmentState // 变形动画与路径约束用到的附件，用到时才创建
+	Skin                 *Skin                            // 当前皮肤
+	Pos                  mgl32.Vec2                       // 根骨骼的位置偏移
+	ConstraintController *ConstraintController
+}
+
+func NewSkeleton(data *SkeletonData) *Skeleton {
+	res := &Skeleton{Data: data, Skin: data.Skin, Deforms: make(map[*Attachment]*AttachmentState)}
+	res.Bones = NewBoneNodes(data.Bones)
+	res.Root = res.Bones[0] // 第一个就是根骨骼
+	for _, item := range data.Slots {
+		res.Slots = append(res.Slots, NewSlotState(item))
+	}
+	res.DrawOrder = slices.Clone(res.Slots) // 原始的顺序不要动
+	for _, item := range data.IkConstraints {
+		res.IkConstraints = append(res.IkConstraints, NewIkConstraintState(item))
+	}
+	for _, item := range data.TransformConstraints {
+		res.TransformConstraints = append(res.TransformConstraints, NewTransformConstraintState(item))
+	}
+	for _, item := range data.PathConstraints {
+		res.PathConstraints = append(res.PathConstraints, NewPathConstraintState(item))
+	}
+	res.fillPathAttachment() // 构建更新顺序需要路径依赖的骨骼
+	res.ConstraintController = NewConstraintController(res.Bones, res.IkConstraints, res.PathConstraints, res.TransformConstraints)
+	return res
+}
+
+// 参考 spine-libgdx 3.8 Skeleton.setSkin，nil 表示使用默认皮肤
+// 插槽按占位名引用附件且每帧重置为初始附件名，附件通过当前皮肤解析就相当于刷新了初始附件
+func (s *Skeleton) SetSkin(skin *Skin) {
+	if skin == nil {
+		skin = s.Data.Skin
+	}
+	if skin == s.Skin {
+		return
+	}
+	s.Skin = skin
+	s.fillPathAttachment() // 路径附件与其依赖的骨骼可能变化，需要重建更新顺序
+	s.ConstraintController.SetSkin(skin)
+}
+
+// 先当前皮肤后默认皮肤，与 spine-libgdx 3.8 Skeleton.getAttachment 的查找顺序一致，没有返回 nil
+func (s *Skeleton) GetAttachment(slot int, name string) *Attachment {
+	if s.Skin != nil {
+		if res := s.Skin.GetAttachment(slot, name); res != nil {
+			return res
+		}
+	}
+	if s.Data.Skin != nil {
+		return s.Data.Skin.GetAttachment(slot, name)
+	}
+	return nil
+}
+
+// 插槽当前显示的附件，骨骼未生效或没有附件时返回 nil
+func (s *Skeleton) GetSlotAttachment(slot *SlotState) *Attachment {
+	if slot.Slot.Bone < 0 || len(slot.CurrAttachment) == 0 || !s.Bones[slot.Slot.Bone].Active {
+		return nil
+	}
+	return s.GetAttachment(slot.Slot.Index, slot.CurrAttachment)
+}
+
+// 附件在当前实例上的顶点数据，第一次使用时创建
+func (s *Skeleton) GetDeform(attachment *Attachment) *AttachmentState {
+	res := s.Deforms[attachment]
+	if res == nil {
+		res = NewAttachmentState(attachment)
+		s.Deforms[attachment] = res
+	}
+	return res
+}
+
+// 使用当前实例的骨骼与变形数据计算附件顶点的世界坐标
+func (s *Skeleton) ComputeWorldVertices(attachment *Attachment, bone int) []mgl32.Vec2 {
+	return attachment.ComputeWorldVertices(s.Bones, bone, s.Deforms[attachment])
+}
+
+// 运行时数据恢复为初始状态，每帧应用动画前调用，防止动画没有改动为 零值
+func (s *Skeleton) SetToSetupPose() {
+	for _, item := range s.Bones {
+		item.SetToSetupPose()
+	}
+	for _, item := range s.Slots {
+		item.SetToSetupPose()
+	}
+	for _, item := range s.Deforms {
+		item.SetToSetupPose()
+	}
+	for _, item := range s.IkConstraints {
+		item.SetToSetupPose()
+	}
+	for _, item := range s.TransformConstraints {
+		item.SetToSetupPose()
+	}
+	for _, item := range s.PathConstraints {
+		item.SetToSetupPose()
+	}
+}
+
+// 应用动画后调用，按依赖顺序计算出世界坐标 世界旋转 世界缩放 与 世界矩阵，并对世界坐标下的对象应用约束
+// 最后按动画修改后的顺序排列插槽
+func (s *Skeleton) UpdateWorldTransform() {
+	s.Root.Offset = s.Pos
+	s.ConstraintController.Update()
+	sort.Slice(s.DrawOrder, func(i, j int) bool {
+		return s.DrawOrder[i].CurrOrder < s.DrawOrder[j].CurrOrder
+	})
+}
+
+func (s *Skeleton) FindSlot(name string) *SlotState {
+	for _, item := range s.Slots {
+		if item.Slot.Name == name {
+			return item
+		}
+	}
+	return nil
+}
+
+func (s *Skeleton) fillPathAttachment() {
+	for _, item := range s.PathConstraints {
+		slot := s.Data.Slots[item.PathConstraint.Target]
+		item.Attachment, item.Deform = nil, nil // 当前皮肤下可能没有路径附件
+		if temp := s.GetAttachment(slot.Index, slot.Attachment); temp != nil && temp.Type == AttachmentPath {
+			item.Attachment = temp
+			item.Deform = s.GetDeform(temp)
+		}
+		item.Bone = slot.Bone
+	}
+}
+
+// 插槽的运行时数据
+type SlotState struct {
+	Slot                     *Slot
+	CurrOrder                int
+	CurrAttachment           string
+	CurrColor, CurrDarkColor mgl32.Vec4
+}
+
+func NewSlotState(slot *Slot) *SlotState {
+	res := &SlotState{Slot: slot}
+	res.SetToSetupPose()
+	return res
+}
+
+func (s *SlotState) SetToSetupPose() {
+	s.CurrOrder = s.Slot.Index
+	s.CurrAttachment = s.Slot.Attachment
+	s.CurrColor = s.Slot.Color
+	s.CurrDarkColor = s.Slot.DarkColor
+}
+
+// 附件顶点的运行时数据，变形动画在初始顶点上叠加偏移
+type AttachmentState struct {
+	Attachment         *Attachment
+	CurrVertices       []mgl32.Vec2
+	CurrWeightVertices [][]*WeightVertex
+}
+
+func NewAttachmentState(attachment *Attachment) *AttachmentState {
+	res := &AttachmentState{Attachment: attachment, CurrVertices: make([]mgl32.Vec2, len(attachment.Vertices))}
+	for _, items := range attachment.WeightVertices {
+		temp := make([]*WeightVertex, 0, len(items))
+		for range items {
+			temp = append(temp, &WeightVertex{})
+		}
+		res.CurrWeightVertices = append(res.CurrWeightVertices, temp)
+	}
+	res.SetToSetupPose()
+	return res
+}
+
+// 复用已分配的顶点，不产生新的对象
+func (a *AttachmentState) SetToSetupPose() {
+	copy(a.CurrVertices, a.Attachment.Vertices)
+	for i, items := range a.CurrWeightVertices {
+		for j, item := range items {
+			*item = *a.Attachment.WeightVertices[i][j]
+		}
+	}
+}
+
+type IkConstraintState struct {
+	IkConstraint      *IkConstraint
+	Active            bool
+	CurrMix           float32
+	CurrSoftness      float32
+	CurrBendDirection int
+	CurrCompress      bool
+	CurrStretch       bool
+}
+
+func NewIkConstraintState(item *IkConstraint) *IkConstraintState {
+	res := &IkConstraintState{IkConstraint: item}
+	res.SetToSetupPose()
+	return res
+}
+
+func (s *IkConstraintState) SetToSetupPose() {
+	s.CurrMix = s.IkConstraint.Mix
+	s.CurrSoftness = s.IkConstraint.Softness
+	s.CurrBendDirection = s.IkConstraint.BendDirection
+	s.CurrCompress = s.IkConstraint.Compress
+	s.CurrStretch = s.IkConstraint.Stretch
+}
+
+type TransformConstraintState struct {
+	TransformConstraint *TransformConstraint
+	Active              bool
+	CurrRotateMix       float32
+	CurrOffsetMix       float32
+	CurrScaleMix        float32
+	CurrShearMix        float32
+}
+
+func NewTransformConstraintState(item *TransformConstraint) *TransformConstraintState {
+	res := &TransformConstraintState{TransformConstraint: item}
+	res.SetToSetupPose()
+	return res
+}
+
+func (s *TransformConstraintState) SetToSetupPose() {
+	s.CurrRotateMix = s.TransformConstraint.RotateMix
+	s.CurrOffsetMix = s.TransformConstraint.OffsetMix
+	s.CurrScaleMix = s.TransformConstraint.ScaleMix
+	s.CurrShearMix = s.TransformConstraint.ShearMix
+}
+
+type PathConstraintState struct {
+	PathConstraint *PathConstraint
+	Active         bool
+	Attachment     *Attachment      // 当前皮肤下对应的 Path
+	Deform         *AttachmentState // Path 的顶点可能被变形动画修改
+	Bone           int              // 对应的骨骼只有 Path 点非 Weight 时用的上，一般都是 Weight 的
+	CurrPosition   float32
+	CurrSpace      float32
+	CurrRotateMix  float32
+	CurrOffsetMix  float32
+}
+
+func NewPathConstraintState(item *PathConstraint) *PathConstraintState {
+	res := &PathConstraintState{PathConstraint: item}
+	res.SetToSetupPose()
+	return res
+}
+
+func (s *PathConstraintState) SetToSetupPose() {
+	s.CurrPosition = s.PathConstraint.Position
+	s.CurrSpace = s.PathConstraint.Space
+	s.CurrRotateMix = s.PathConstraint.RotateMix
+	s.CurrOffsetMix = s.PathConstraint.OffsetMix
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// 一个骨骼实例与播放在它上面的动画
+type SkeletonInstance struct {
+	Skeleton       *Skeleton
+	AnimController *AnimController
+}
+
+func NewSkeletonInstance(skeleton *Skeleton, anim *Animation) *SkeletonInstance {
+	return &SkeletonInstance{Skeleton: skeleton, AnimController: NewAnimController(anim, skeleton)}
+}
+
+// 应用动画，计算骨骼世界数据并应用约束，只修改自己的运行时数据
+func (i *SkeletonInstance) Update(delta float32) {
+	i.Skeleton.SetToSetupPose()
+	i.AnimController.Update(delta)
+	i.Skeleton.UpdateWorldTransform()
+}
+
+// 批量更新多个互相独立的实例，每个实例同一时刻只在一个 goroutine 中更新
+// 实例之间只共享只读的 SkeletonData，结果与 goroutine 数量和调度顺序无关
+// 注意事件监听会在工作 goroutine 中触发，监听函数需要自己保证并发安全
+type SkeletonSet struct {
+	Instances []*SkeletonInstance
+	Workers   int // 最多同时更新的 goroutine 数量，<= 0 时使用 GOMAXPROCS
+}
+
+func NewSkeletonSet(workers int) *SkeletonSet {
+	return &SkeletonSet{Workers: workers}
+}
+
+func (s *SkeletonSet) Add(instance *SkeletonInstance) {
+	s.Instances = append(s.Instances, instance)
+}
+
+// 所有实例推进同样的 delta 秒，返回时全部更新完成，之后可以安全地绘制
+func (s *SkeletonSet) Update(delta float32) {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(s.Instances))
+	if workers <= 1 { // 没必要开 goroutine
+		for _, item := range s.Instances {
+			item.Update(delta)
+		}
+		return
+	}
+	next := atomic.Int64{} // 按顺序领取下一个实例，耗时不同的实例也能分配均匀
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for {
+				index := int(next.Add(1)) - 1
+				if index >= len(s.Instances) {
+					return
+				}
+				s.Instances[index].Update(delta)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,917 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"io"
+	"io/fs"
+)
+
+// 保持 key 顺序的 json 对象，动画、事件等的顺序与导出时一致
+type jsonMap struct {
+	Keys   []string
+	Values []json.RawMessage
+}
+
+func (m *jsonMap) UnmarshalJSON(bs []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != json.Delim('{') {
+		return fmt.Errorf("expect object but got %v", token)
+	}
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return err
+		}
+		value := json.RawMessage{}
+		if err = decoder.Decode(&value); err != nil {
+			return err
+		}
+		m.Keys = append(m.Keys, token.(string))
+		m.Values = append(m.Values, value)
+	}
+	return nil
+}
+
+func (m jsonMap) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBufferString("{")
+	for i, key := range m.Keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		bs, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(bs)
+		buf.WriteByte(':')
+		buf.Write(m.Values[i])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (m *jsonMap) Set(key string, value any) {
+	bs, err := json.Marshal(value)
+	HandleErr(err)
+	for i, item := range m.Keys {
+		if item == key {
+			m.Values[i] = bs
+			return
+		}
+	}
+	m.Keys = append(m.Keys, key)
+	m.Values = append(m.Values, bs)
+}
+
+// 与默认值相同时省略
+func (m *jsonMap) SetDefault(key string, value, def any) {
+	if value != def {
+		m.Set(key, value)
+	}
+}
+
+type jsonSkel struct {
+	Skeleton struct {
+		Hash, Spine         string
+		X, Y, Width, Height float32
+		Fps                 *float32
+		Images, Audio       string
+	}
+	Bones      []json.RawMessage
+	Slots      []json.RawMessage
+	Ik         []json.RawMessage
+	Transform  []json.RawMessage
+	Path       []json.RawMessage
+	Skins      []json.RawMessage
+	Events     jsonMap
+	Animations jsonMap
+}
+
+type jsonBone struct {
+	Name, Parent           string
+	Length, Rotation, X, Y float32
+	ScaleX, ScaleY         float32
+	ShearX, ShearY         float32
+	Transform              string
+	Skin                   bool
+	Color                  string
+}
+
+type jsonSlot struct {
+	Name, Bone, Color, Dark, Attachment, Blend string
+}
+
+// ik transform path 约束共用
+type jsonConstraint struct {
+	Name, Target                                string
+	Order                                       int
+	Skin                                        bool
+	Bones                                       []string
+	Mix, Softness                               float32
+	BendPositive, Compress, Stretch, Uniform    bool
+	Local, Relative                             bool
+	Rotation, X, Y, ScaleX, ScaleY, ShearY      float32
+	RotateMix, TranslateMix, ScaleMix, ShearMix float32
+	PositionMode, SpacingMode, RotateMode       string
+	Position, Spacing                           float32
+}
+
+type jsonSkin struct {
+	Name                       string
+	Bones, Ik, Transform, Path []string
+	Attachments                jsonMap
+}
+
+type jsonAttachment struct {
+	Type, Name, Path, Color                       string
+	X, Y, ScaleX, ScaleY, Rotation, Width, Height float32
+	UVs                                           []float32 `json:"uvs"`
+	Triangles, Edges                              []uint16
+	Vertices                                      []float32
+	Hull, VertexCount                             int
+	Parent, Skin                                  string
+	Deform, Closed, ConstantSpeed                 bool
+	Lengths                                       []float32
+	End                                           string
+}
+
+type jsonEventData struct {
+	Int                    int
+	Float, Volume, Balance float32
+	String, Audio          string
+}
+
+type jsonAnimation struct {
+	Slots, Bones, Ik, Transform, Path, Deform jsonMap
+	DrawOrder                                 []json.RawMessage // 旧版本为 draworder，json 解析忽略大小写
+	Events                                    []json.RawMessage
+}
+
+// 所有时间线的关键帧共用
+type jsonKeyFrame struct {
+	Time                                        float32
+	Curve                                       json.RawMessage
+	C2, C3, C4                                  float32
+	Name                                        *string
+	Color, Light, Dark                          string
+	Angle, X, Y                                 float32
+	Mix, Softness                               float32
+	BendPositive, Compress, Stretch             bool
+	RotateMix, TranslateMix, ScaleMix, ShearMix float32
+	Position, Spacing                           float32
+	Offset                                      int
+	Vertices                                    []float32
+	Offsets                                     []struct {
+		Slot   string
+		Offset int
+	}
+	Int                    *int
+	Float, Volume, Balance *float32
+	String                 *string
+}
+
+// 从 fsys 中加载 json 格式的骨骼
+func LoadSkelJsonFS(fsys fs.FS, name string) (*SkeletonData, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadSkelJson(file)
+}
+
+// 参考 spine-libgdx 3.8 SkeletonJson，结果与 LoadSkel 解析对应的二进制文件相同
+// 解析失败返回 *ParseError，只有 json 语法错误时有 Offset
+func LoadSkelJson(r io.Reader) (res *SkeletonData, err error) {
+	data := &jsonSkel{}
+	if err = json.NewDecoder(r).Decode(data); err != nil {
+		res := &ParseError{Section: "json", Err: err}
+		syntaxErr := &json.SyntaxError{}
+		typeErr := &json.UnmarshalTypeError{}
+		if errors.As(err, &syntaxErr) {
+			res.Offset = syntaxErr.Offset
+		} else if errors.As(err, &typeErr) {
+			res.Offset = typeErr.Offset
+		}
+		return nil, res
+	}
+	parser := &jsonParser{Section: "header", Skel: &SkeletonData{}}
+	defer recoverParseErr(&err, func() (string, int64) {
+		return parser.Section, 0
+	})
+	parser.parse(data)
+	return parser.Skel, nil
+}
+
+type jsonParser struct {
+	Section string
+	Skel    *SkeletonData
+}
+
+func (p *jsonParser) parse(data *jsonSkel) {
+	skel := p.Skel
+	skel.Header = &SkelHeader{
+		Hash:         data.Skeleton.Hash,
+		Version:      data.Skeleton.Spine,
+		Pos:          mgl32.Vec2{data.Skeleton.X, data.Skeleton.Y},
+		Size:         mgl32.Vec2{data.Skeleton.Width, data.Skeleton.Height},
+		Nonessential: data.Skeleton.Fps != nil, // 勾选 nonessential 导出时才有 fps
+		ImagesPath:   data.Skeleton.Images,
+		AudioPath:    data.Skeleton.Audio,
+	}
+	if data.Skeleton.Fps != nil {
+		skel.Header.Fps = *data.Skeleton.Fps
+	}
+	p.Section = "bones"
+	for _, item := range data.Bones {
+		skel.Bones = append(skel.Bones, p.parseBone(item))
+	}
+	p.Section = "slots"
+	for i, item := range data.Slots {
+		slot := p.parseSlot(item)
+		slot.Index = i
+		skel.Slots = append(skel.Slots, slot)
+	}
+	p.Section = "ik constraints"
+	for _, item := range data.Ik {
+		skel.IkConstraints = append(skel.IkConstraints, p.parseIkConstraint(item))
+	}
+	p.Section = "transform constraints"
+	for _, item := range data.Transform {
+		skel.TransformConstraints = append(skel.TransformConstraints, p.parseTransformConstraint(item))
+	}
+	p.Section = "path constraints"
+	for _, item := range data.Path {
+		skel.PathConstraints = append(skel.PathConstraints, p.parsePathConstraint(item))
+	}
+	// 与二进制一致，默认皮肤总是第一个
+	skel.Skins = []*Skin{{Name: "default", Attachments: make([]*Attachment, 0)}}
+	for _, item := range data.Skins {
+		skin := p.parseSkin(item)
+		if skin.Name == "default" {
+			skel.Skins[0] = skin
+		} else {
+			skel.Skins = append(skel.Skins, skin)
+		}
+	}
+	skel.Skin = skel.Skins[0]
+	p.Section = "linked meshes"
+	linkMeshes(skel.Skins)
+	p.Section = "events"
+	for i, name := range data.Events.Keys {
+		skel.Events = append(skel.Events, p.parseEventData(name, data.Events.Values[i]))
+	}
+	p.Section = "animations"
+	for i, name := range data.Animations.Keys {
+		skel.Animations = append(skel.Animations, p.parseAnimation(name, data.Animations.Values[i]))
+	}
+}
+
+func (p *jsonParser) parseBone(raw json.RawMessage) *Bone {
+	data := &jsonBone{ScaleX: 1, ScaleY: 1, Transform: "normal"}
+	unmarshal(raw, data)
+	res := &Bone{
+		Name:          data.Name,
+		Parent:        -1,
+		Rotate:        data.Rotation,
+		Pos:           mgl32.Vec2{data.X, data.Y},
+		Scale:         mgl32.Vec2{data.ScaleX, data.ScaleY},
+		Shear:         mgl32.Vec2{data.ShearX, data.ShearY},
+		Length:        data.Length,
+		TransformMode: uint8(enumIndex(data.Transform, "normal", "onlyTranslation", "noRotationOrReflection", "noScale", "noScaleOrReflection")),
+		SkinRequire:   data.Skin,
+	}
+	if len(data.Parent) > 0 {
+		res.Parent = p.findBone(data.Parent)
+	}
+	if len(data.Color) > 0 {
+		res.Color = parseRgba(data.Color)
+	}
+	return res
+}
+
+func (p *jsonParser) parseSlot(raw json.RawMessage) *Slot {
+	data := &jsonSlot{Color: "ffffffff", Blend: "normal"}
+	unmarshal(raw, data)
+	res := &Slot{
+		Name:       data.Name,
+		Bone:       p.findBone(data.Bone),
+		Color:      parseRgba(data.Color),
+		DarkColor:  mgl32.Vec4{1, 1, 1, 1}, // 二进制中没有暗色时为 -1
+		Attachment: data.Attachment,
+		BlendMode:  uint8(enumIndex(data.Blend, "normal", "additive", "multiply", "screen")),
+	}
+	if len(data.Dark) > 0 {
+		res.DarkColor = parseRgb(data.Dark)
+	}
+	return res
+}
+
+func (p *jsonParser) parseIkConstraint(raw json.RawMessage) *IkConstraint {
+	data := &jsonConstraint{Mix: 1, BendPositive: true}
+	unmarshal(raw, data)
+	res := &IkConstraint{
+		Name:          data.Name,
+		Order:         data.Order,
+		SkinRequire:   data.Skin,
+		Target:        p.findBone(data.Target),
+		Mix:           data.Mix,
+		Softness:      data.Softness,
+		BendDirection: bendDirection(data.BendPositive),
+		Compress:      data.Compress,
+		Stretch:       data.Stretch,
+		Uniform:       data.Uniform,
+	}
+	for _, item := range data.Bones {
+		res.Bones = append(res.Bones, p.findBone(item))
+	}
+	return res
+}
+
+func (p *jsonParser) parseTransformConstraint(raw json.RawMessage) *TransformConstraint {
+	data := &jsonConstraint{RotateMix: 1, TranslateMix: 1, ScaleMix: 1, ShearMix: 1}
+	unmarshal(raw, data)
+	res := &TransformConstraint{
+		Name:        data.Name,
+		Order:       data.Order,
+		SkinRequire: data.Skin,
+		Target:      p.findBone(data.Target),
+		Local:       data.Local,
+		Relative:    data.Relative,
+		Rotate:      data.Rotation,
+		Offset:      mgl32.Vec2{data.X, data.Y},
+		Scale:       mgl32.Vec2{data.ScaleX, data.ScaleY},
+		ShearY:      data.ShearY,
+		RotateMix:   data.RotateMix,
+		OffsetMix:   data.TranslateMix,
+		ScaleMix:    data.ScaleMix,
+		ShearMix:    data.ShearMix,
+	}
+	for _, item := range data.Bones {
+		res.Bones = append(res.Bones, p.findBone(item))
+	}
+	return res
+}
+
+func (p *jsonParser) parsePathConstraint(raw json.RawMessage) *PathConstraint {
+	data := &jsonConstraint{PositionMode: "percent", SpacingMode: "length", RotateMode: "tangent", RotateMix: 1, TranslateMix: 1}
+	unmarshal(raw, data)
+	res := &PathConstraint{
+		Name:         data.Name,
+		Order:        data.Order,
+		SkinRequire:  data.Skin,
+		Target:       p.findSlot(data.Target),
+		PositionMode: uint8(enumIndex(data.PositionMode, "fixed", "percent")),
+		SpaceMode:    uint8(enumIndex(data.SpacingMode, "length", "fixed", "percent")),
+		RotateMode:   uint8(enumIndex(data.RotateMode, "tangent", "chain", "chainScale")),
+		Rotate:       data.Rotation,
+		Position:     data.Position,
+		Space:        data.Spacing,
+		RotateMix:    data.RotateMix,
+		OffsetMix:    data.TranslateMix,
+	}
+	for _, item := range data.Bones {
+		res.Bones = append(res.Bones, p.findBone(item))
+	}
+	return res
+}
+
+func (p *jsonParser) parseSkin(raw json.RawMessage) *Skin {
+	data := &jsonSkin{}
+	unmarshal(raw, data)
+	p.Section = "skin:" + data.Name
+	res := &Skin{
+		Name:                 data.Name,
+		Bones:                make([]int, 0),
+		IkConstraints:        make([]int, 0),
+		TransformConstraints: make([]int, 0),
+		PathConstraints:      make([]int, 0),
+		Attachments:          make([]*Attachment, 0),
+	}
+	for _, item := range data.Bones {
+		res.Bones = append(res.Bones, p.findBone(item))
+	}
+	for _, item := range data.Ik {
+		res.IkConstraints = append(res.IkConstraints, p.findIkConstraint(item))
+	}
+	for _, item := range data.Transform {
+		res.TransformConstraints = append(res.TransformConstraints, p.findTransformConstraint(item))
+	}
+	for _, item := range data.Path {
+		res.PathConstraints = append(res.PathConstraints, p.findPathConstraint(item))
+	}
+	for i, slotName := range data.Attachments.Keys {
+		slot := p.findSlot(slotName)
+		attachments := &jsonMap{}
+		unmarshal(data.Attachments.Values[i], attachments)
+		for j, name := range attachments.Keys {
+			res.Attachments = append(res.Attachments, p.parseAttachment(slot, name, attachments.Values[j]))
+		}
+	}
+	return res
+}
+
+func (p *jsonParser) parseAttachment(slot int, placeholder string, raw json.RawMessage) *Attachment {
+	data := &jsonAttachment{Type: "region", ScaleX: 1, ScaleY: 1, Deform: true}
+	unmarshal(raw, data)
+	name := data.Name
+	if len(name) == 0 {
+		name = placeholder
+	}
+	res := &Attachment{
+		Name:     placeholder,
+		RealName: data.Name,
+		Slot:     slot,
+		Type:     uint8(enumIndex(data.Type, "region", "boundingbox", "mesh", "linkedmesh", "path", "point", "clipping")),
+		Path:     data.Path,
+	}
+	if len(data.Color) > 0 {
+		res.Color = parseRgba(data.Color)
+	} else if res.Type == AttachmentRegion || res.Type == AttachmentMesh || res.Type == AttachmentLinkMesh {
+		res.Color = mgl32.Vec4{1, 1, 1, 1}
+	}
+	if len(res.Path) == 0 && (res.Type == AttachmentRegion || res.Type == AttachmentMesh || res.Type == AttachmentLinkMesh) {
+		res.Path = name
+	}
+	switch res.Type {
+	case AttachmentRegion:
+		res.Rotate = data.Rotation
+		res.Pos = mgl32.Vec2{data.X, data.Y}
+		res.Scale = mgl32.Vec2{data.ScaleX, data.ScaleY}
+		res.Size = mgl32.Vec2{data.Width, data.Height}
+	case AttachmentMesh:
+		for i := 0; i+1 < len(data.UVs); i += 2 {
+			res.UVs = append(res.UVs, mgl32.Vec2{data.UVs[i], data.UVs[i+1]})
+		}
+		res.Indices = data.Triangles
+		res.Weight, res.Vertices, res.WeightVertices = parseJsonVertices(data.Vertices, len(res.UVs))
+		res.HullLength = data.Hull
+		res.Edges = data.Edges
+		res.Size = mgl32.Vec2{data.Width, data.Height}
+	case AttachmentLinkMesh:
+		res.ParentSkin = data.Skin
+		res.ParentName = data.Parent
+		res.InheritDeform = data.Deform
+		res.Size = mgl32.Vec2{data.Width, data.Height}
+	case AttachmentBoundBox:
+		res.Weight, res.Vertices, res.WeightVertices = parseJsonVertices(data.Vertices, data.VertexCount)
+	case AttachmentPath:
+		res.Close = data.Closed
+		res.ConstantSpeed = data.ConstantSpeed
+		res.Weight, res.Vertices, res.WeightVertices = parseJsonVertices(data.Vertices, data.VertexCount)
+		res.Lengths = data.Lengths
+	case AttachmentPoint:
+		res.Rotate = data.Rotation
+		res.Pos = mgl32.Vec2{data.X, data.Y}
+	case AttachmentClip:
+		res.EndSlot = p.findSlot(data.End)
+		res.Weight, res.Vertices, res.WeightVertices = parseJsonVertices(data.Vertices, data.VertexCount)
+	}
+	return res
+}
+
+// 数量与顶点数不同的是 Weight 的，每个顶点为 骨骼数 [骨骼 x y 权重]...
+func parseJsonVertices(values []float32, count int) (bool, []mgl32.Vec2, [][]*WeightVertex) {
+	vertices := make([]mgl32.Vec2, 0)
+	weightVertices := make([][]*WeightVertex, 0)
+	if len(values) == count*2 {
+		for i := 0; i < count; i++ {
+			vertices = append(vertices, mgl32.Vec2{values[i*2], values[i*2+1]})
+		}
+		return false, vertices, weightVertices
+	}
+	idx := 0
+	for i := 0; i < count; i++ {
+		boneCount := int(values[idx])
+		idx++
+		temp := make([]*WeightVertex, 0)
+		for j := 0; j < boneCount; j++ {
+			temp = append(temp, &WeightVertex{
+				Bone:   int(values[idx]),
+				Offset: mgl32.Vec2{values[idx+1], values[idx+2]},
+				Weight: values[idx+3],
+			})
+			idx += 4
+		}
+		weightVertices = append(weightVertices, temp)
+	}
+	return true, vertices, weightVertices
+}
+
+func (p *jsonParser) parseEventData(name string, raw json.RawMessage) *EventData {
+	data := &jsonEventData{Volume: 1}
+	unmarshal(raw, data)
+	res := &EventData{
+		Name:      name,
+		Int:       data.Int,
+		Float:     data.Float,
+		String:    data.String,
+		AudioPath: data.Audio,
+	}
+	if len(res.AudioPath) > 0 { // 与二进制一致，没有音频时不记录音量
+		res.Volume = data.Volume
+		res.Balance = data.Balance
+	}
+	return res
+}
+
+func (p *jsonParser) parseAnimation(name string, raw json.RawMessage) *Animation {
+	p.Section = "animation:" + name
+	data := &jsonAnimation{}
+	unmarshal(raw, data)
+	timelines := make([]*Timeline, 0)
+	// slot
+	for i, slotName := range data.Slots.Keys {
+		slot := p.findSlot(slotName)
+		items := &jsonMap{}
+		unmarshal(data.Slots.Values[i], items)
+		for j, type0 := range items.Keys {
+			temp := &Timeline{Slot: slot}
+			frames := parseJsonKeyFrames(items.Values[j], 0)
+			switch type0 {
+			case "attachment":
+				temp.Type = TimelineAttachment
+				for _, frame := range frames {
+					keyFrame := &KeyFrame{Time: frame.Time}
+					if frame.Name != nil {
+						keyFrame.Attachment = *frame.Name
+					}
+					temp.KeyFrames = append(temp.KeyFrames, keyFrame)
+				}
+			case "color":
+				temp.Type = TimelineColor
+				temp.KeyFrames = newJsonKeyFrames(frames, func(frame *jsonKeyFrame) *KeyFrame {
+					return &KeyFrame{Color: parseRgba(frame.Color)}
+				})
+			case "twoColor":
+				temp.Type = TimelineTwoColor
+				temp.KeyFrames = newJsonKeyFrames(frames, func(frame *jsonKeyFrame) *KeyFrame {
+					res := &KeyFrame{Color: parseRgba(frame.Light), DarkColor: parseRgb(frame.Dark)}
+					res.DarkColor[3] = 1
+					return res
+				})
+			default:
+				panic(fmt.Errorf("unknown slot type: %v", type0))
+			}
+			timelines = append(timelines, temp)
+		}
+	}
+	// bone
+	for i, boneName := range data.Bones.Keys {
+		bone := p.findBone(boneName)
+		items := &jsonMap{}
+		unmarshal(data.Bones.Values[i], items)
+		for j, type0 := range items.Keys {
+			temp := &Timeline{Bone: bone}
+			switch type0 {
+			case "rotate":
+				temp.Type = TimelineRotate
+				temp.KeyFrames = newJsonKeyFrames(parseJsonKeyFrames(items.Values[j], 0), func(frame *jsonKeyFrame) *KeyFrame {
+					return &KeyFrame{Rotate: frame.Angle}
+				})
+			case "translate":
+				temp.Type = TimelineTranslate
+				temp.KeyFrames = newJsonKeyFrames(parseJsonKeyFrames(items.Values[j], 0), func(frame *jsonKeyFrame) *KeyFrame {
+					return &KeyFrame{Offset: mgl32.Vec2{frame.X, frame.Y}}
+				})
+			case "scale":
+				temp.Type = TimelineScale
+				temp.KeyFrames = newJsonKeyFrames(parseJsonKeyFrames(items.Values[j], 1), func(frame *jsonKeyFrame) *KeyFrame {
+					return &KeyFrame{Scale: mgl32.Vec2{frame.X, frame.Y}}
+				})
+			case "shear":
+				temp.Type = TimelineShear
+				temp.KeyFrames = newJsonKeyFrames(parseJsonKeyFrames(items.Values[j], 0), func(frame *jsonKeyFrame) *KeyFrame {
+					return &KeyFrame{Shear: mgl32.Vec2{frame.X, frame.Y}}
+				})
+			default:
+				panic(fmt.Errorf("unknown bone type: %v", type0))
+			}
+			timelines = append(timelines, temp)
+		}
+	}
+	// IK constraint
+	for i, constraintName := range data.Ik.Keys {
+		timelines = append(timelines, &Timeline{
+			Type:         TimelineIkConstraint,
+			IkConstraint: p.findIkConstraint(constraintName),
+			KeyFrames: newJsonKeyFrames(parseJsonKeyFrames(data.Ik.Values[i], 0), func(frame *jsonKeyFrame) *KeyFrame {
+				return &KeyFrame{
+					Mix:           frame.Mix,
+					Softness:      frame.Softness,
+					BendDirection: bendDirection(frame.BendPositive),
+					Compress:      frame.Compress,
+					Stretch:       frame.Stretch,
+				}
+			}),
+		})
+	}
+	// Transform constraint
+	for i, constraintName := range data.Transform.Keys {
+		timelines = append(timelines, &Timeline{
+			Type:                TimelineTransformConstraint,
+			TransformConstraint: p.findTransformConstraint(constraintName),
+			KeyFrames: newJsonKeyFrames(parseJsonKeyFrames(data.Transform.Values[i], 0), func(frame *jsonKeyFrame) *KeyFrame {
+				return &KeyFrame{
+					RotateMix: frame.RotateMix,
+					OffsetMix: frame.TranslateMix,
+					ScaleMix:  frame.ScaleMix,
+					ShearMix:  frame.ShearMix,
+				}
+			}),
+		})
+	}
+	// Path constraint
+	for i, constraintName := range data.Path.Keys {
+		pathConstraint := p.findPathConstraint(constraintName)
+		items := &jsonMap{}
+		unmarshal(data.Path.Values[i], items)
+		for j, type0 := range items.Keys {
+			temp := &Timeline{PathConstraint: pathConstraint}
+			frames := parseJsonKeyFrames(items.Values[j], 0)
+			switch type0 {
+			case "position":
+				temp.Type = TimelinePathConstraintPosition
+				temp.KeyFrames = newJsonKeyFrames(frames, func(frame *jsonKeyFrame) *KeyFrame {
+					return &KeyFrame{Position: frame.Position}
+				})
+			case "spacing":
+				temp.Type = TimelinePathConstraintSpace
+				temp.KeyFrames = newJsonKeyFrames(frames, func(frame *jsonKeyFrame) *KeyFrame {
+					return &KeyFrame{Space: frame.Spacing}
+				})
+			case "mix":
+				temp.Type = TimelinePathConstraintMix
+				temp.KeyFrames = newJsonKeyFrames(frames, func(frame *jsonKeyFrame) *KeyFrame {
+					return &KeyFrame{RotateMix: frame.RotateMix, OffsetMix: frame.TranslateMix}
+				})
+			default:
+				panic(fmt.Errorf("unknown path type: %v", type0))
+			}
+			timelines = append(timelines, temp)
+		}
+	}
+	// Deform
+	for i, skinName := range data.Deform.Keys {
+		skinIndex := p.findSkin(skinName)
+		skin := p.Skel.Skins[skinIndex]
+		slots := &jsonMap{}
+		unmarshal(data.Deform.Values[i], slots)
+		for j, slotName := range slots.Keys {
+			slot := p.findSlot(slotName)
+			attachments := &jsonMap{}
+			unmarshal(slots.Values[j], attachments)
+			for k, attachmentName := range attachments.Keys {
+				attachment := skin.GetAttachment(slot, attachmentName)
+				if attachment == nil || attachment.Type == AttachmentRegion || attachment.Type == AttachmentPoint {
+					panic(fmt.Errorf("not find attachment %s", AttachmentKey(attachmentName, slot)))
+				}
+				timelines = append(timelines, &Timeline{
+					Type:       TimelineDeform,
+					Slot:       slot,
+					Skin:       skinIndex,
+					Attachment: attachmentName,
+					KeyFrames: newJsonKeyFrames(parseJsonKeyFrames(attachments.Values[k], 0), func(frame *jsonKeyFrame) *KeyFrame {
+						return newDeformKeyFrame(attachment, frame.Time, frame.Offset, frame.Vertices)
+					}),
+				})
+			}
+		}
+	}
+	// Draw order
+	if len(data.DrawOrder) > 0 {
+		temp := &Timeline{Type: TimelineDrawOrder}
+		for _, raw := range data.DrawOrder {
+			frame := &jsonKeyFrame{}
+			unmarshal(raw, frame)
+			offsets := make([][2]int, 0)
+			for _, item := range frame.Offsets {
+				offsets = append(offsets, [2]int{p.findSlot(item.Slot), item.Offset})
+			}
+			temp.KeyFrames = append(temp.KeyFrames, &KeyFrame{
+				Time:      frame.Time,
+				DrawOrder: newDrawOrder(len(p.Skel.Slots), offsets),
+			})
+		}
+		timelines = append(timelines, temp)
+	}
+	// Event
+	if len(data.Events) > 0 {
+		temp := &Timeline{Type: TimelineEvent}
+		for _, raw := range data.Events {
+			frame := &jsonKeyFrame{}
+			unmarshal(raw, frame)
+			if frame.Name == nil {
+				panic(errors.New("event without name"))
+			}
+			event := p.newEvent(frame, p.Skel.Events[p.findEvent(*frame.Name)])
+			temp.KeyFrames = append(temp.KeyFrames, &KeyFrame{
+				Time:  event.Time,
+				Event: event,
+			})
+		}
+		timelines = append(timelines, temp)
+	}
+	return newAnimation(name, timelines)
+}
+
+// 关键帧数值默认取 EventData 中的
+func (p *jsonParser) newEvent(frame *jsonKeyFrame, data *EventData) *Event {
+	res := &Event{
+		Data:    data,
+		Time:    frame.Time,
+		Int:     data.Int,
+		Float:   data.Float,
+		String:  data.String,
+		Volume:  data.Volume,
+		Balance: data.Balance,
+	}
+	if frame.Int != nil {
+		res.Int = *frame.Int
+	}
+	if frame.Float != nil {
+		res.Float = *frame.Float
+	}
+	if frame.String != nil {
+		res.String = *frame.String
+	}
+	if len(data.AudioPath) > 0 { // 与二进制一致，有音频时才能覆盖
+		if frame.Volume != nil {
+			res.Volume = *frame.Volume
+		}
+		if frame.Balance != nil {
+			res.Balance = *frame.Balance
+		}
+	}
+	return res
+}
+
+// xy 为平移、缩放等时间线 x y 的默认值
+func parseJsonKeyFrames(raw json.RawMessage, xy float32) []*jsonKeyFrame {
+	items := make([]json.RawMessage, 0)
+	unmarshal(raw, &items)
+	res := make([]*jsonKeyFrame, 0)
+	for _, item := range items {
+		frame := &jsonKeyFrame{
+			C3: 1, C4: 1, X: xy, Y: xy,
+			Mix: 1, BendPositive: true,
+			RotateMix: 1, TranslateMix: 1, ScaleMix: 1, ShearMix: 1,
+		}
+		unmarshal(item, frame)
+		res = append(res, frame)
+	}
+	return res
+}
+
+// 与二进制一致，除最后一帧外都有曲线
+func newJsonKeyFrames(frames []*jsonKeyFrame, create func(frame *jsonKeyFrame) *KeyFrame) []*KeyFrame {
+	res := make([]*KeyFrame, 0)
+	for i, frame := range frames {
+		keyFrame := create(frame)
+		keyFrame.Time = frame.Time
+		if i < len(frames)-1 {
+			keyFrame.Curve = parseJsonCurve(frame)
+		}
+		res = append(res, keyFrame)
+	}
+	return res
+}
+
+// 3.8 为 curve + c2 c3 c4，之前的版本为 [cx1, cy1, cx2, cy2]
+func parseJsonCurve(frame *jsonKeyFrame) *Curve {
+	res := &Curve{Type: CurveLinear}
+	if len(frame.Curve) == 0 {
+		return res
+	}
+	str := ""
+	if json.Unmarshal(frame.Curve, &str) == nil {
+		if str != "stepped" {
+			panic(fmt.Errorf("unknown curve type: %v", str))
+		}
+		res.Type = CurveStepped
+		return res
+	}
+	values := make([]float32, 0)
+	if json.Unmarshal(frame.Curve, &values) == nil && len(values) == 4 {
+		res.Type = CurveBezier
+		res.Data = [2]mgl32.Vec2{{values[0], values[1]}, {values[2], values[3]}}
+		return res
+	}
+	cx1 := float32(0)
+	unmarshal(frame.Curve, &cx1)
+	res.Type = CurveBezier
+	res.Data = [2]mgl32.Vec2{{cx1, frame.C2}, {frame.C3, frame.C4}}
+	return res
+}
+
+func (p *jsonParser) findBone(name string) int {
+	for i, item := range p.Skel.Bones {
+		if item.Name == name {
+			return i
+		}
+	}
+	panic(fmt.Errorf("not find bone %s", name))
+}
+
+func (p *jsonParser) findSlot(name string) int {
+	for i, item := range p.Skel.Slots {
+		if item.Name == name {
+			return i
+		}
+	}
+	panic(fmt.Errorf("not find slot %s", name))
+}
+
+func (p *jsonParser) findIkConstraint(name string) int {
+	for i, item := range p.Skel.IkConstraints {
+		if item.Name == name {
+			return i
+		}
+	}
+	panic(fmt.Errorf("not find ik constraint %s", name))
+}
+
+func (p *jsonParser) findTransformConstraint(name string) int {
+	for i, item := range p.Skel.TransformConstraints {
+		if item.Name == name {
+			return i
+		}
+	}
+	panic(fmt.Errorf("not find transform constraint %s", name))
+}
+
+func (p *jsonParser) findPathConstraint(name string) int {
+	for i, item := range p.Skel.PathConstraints {
+		if item.Name == name {
+			return i
+		}
+	}
+	panic(fmt.Errorf("not find path constraint %s", name))
+}
+
+func (p *jsonParser) findSkin(name string) int {
+	for i, item := range p.Skel.Skins {
+		if item.Name == name {
+			return i
+		}
+	}
+	panic(fmt.Errorf("not find skin %s", name))
+}
+
+func (p *jsonParser) findEvent(name string) int {
+	for i, item := range p.Skel.Events {
+		if item.Name == name {
+			return i
+		}
+	}
+	panic(fmt.Errorf("not find event %s", name))
+}
+
+func unmarshal(raw json.RawMessage, desc any) {
+	HandleErr(json.Unmarshal(raw, desc))
+}
+
+func enumIndex(value string, items ...string) int {
+	for i, item := range items {
+		if item == value {
+			return i
+		}
+	}
+	panic(fmt.Errorf("unknown value %s, expect one of %v", value, items))
+}
+
+func bendDirection(positive bool) int {
+	if positive {
+		return 1
+	}
+	return -1
+}
+
+// rrggbbaa
+func parseRgba(value string) mgl32.Vec4 {
+	bs, err := hex.DecodeString(value)
+	HandleErr(err)
+	if len(bs) != 4 {
+		panic(fmt.Errorf("invalid color %s", value))
+	}
+	return mgl32.Vec4{float32(bs[0]) / 0xFF, float32(bs[1]) / 0xFF, float32(bs[2]) / 0xFF, float32(bs[3]) / 0xFF}
+}
+
+// rrggbb，二进制中为 rgb888 的 int，按 readClr 读取时第一个字节为 0，这里保持一致
+func parseRgb(value string) mgl32.Vec4 {
+	bs, err := hex.DecodeString(value)
+	HandleErr(err)
+	if len(bs) != 3 {
+		panic(fmt.Errorf("invalid color %s", value))
+	}
+	return mgl32.Vec4{0, float32(bs[0]) / 0xFF, float32(bs[1]) / 0xFF, float32(bs[2]) / 0xFF}
+}
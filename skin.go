@@ -0,0 +1,74 @@
+package main
+
+import "slices"
+
+// 按插槽与占位名查找附件，没有返回 nil
+func (s *Skin) GetAttachment(slot int, name string) *Attachment {
+	for _, item := range s.Attachments {
+		if item.Slot == slot && item.Name == name {
+			return item
+		}
+	}
+	return nil
+}
+
+func (s *Skin) SetAttachment(attachment *Attachment) {
+	for i, item := range s.Attachments {
+		if item.Slot == attachment.Slot && item.Name == attachment.Name {
+			s.Attachments[i] = attachment // 同一插槽同名的直接替换
+			return
+		}
+	}
+	s.Attachments = append(s.Attachments, attachment)
+}
+
+// 参考 spine-libgdx 3.8 Skin.addSkin 合并骨骼、约束与附件，附件与原皮肤共享
+func (s *Skin) AddSkin(skin *Skin) {
+	s.Bones = appendUnique(s.Bones, skin.Bones)
+	s.IkConstraints = appendUnique(s.IkConstraints, skin.IkConstraints)
+	s.TransformConstraints = appendUnique(s.TransformConstraints, skin.TransformConstraints)
+	s.PathConstraints = appendUnique(s.PathConstraints, skin.PathConstraints)
+	for _, item := range skin.Attachments {
+		s.SetAttachment(item)
+	}
+}
+
+// 组合多个皮肤为一个自定义皮肤，后面的皮肤覆盖前面皮肤同一位置的附件，例如武器取一个皮肤，服装取另一个皮肤
+func NewSkin(name string, skins ...*Skin) *Skin {
+	res := &Skin{Name: name, Attachments: make([]*Attachment, 0)}
+	for _, skin := range skins {
+		res.AddSkin(skin)
+	}
+	return res
+}
+
+func (s *SkeletonData) FindSkin(name string) *Skin {
+	for _, item := range s.Skins {
+		if item.Name == name {
+			return item
+		}
+	}
+	return nil
+}
+
+// 变形动画作用于 target 时受影响的附件，包含继承变形的链接网格
+func (s *SkeletonData) GetDeformAttachments(target *Attachment) []*Attachment {
+	res := make([]*Attachment, 0)
+	for _, skin := range s.Skins {
+		for _, item := range skin.Attachments {
+			if item.GetDeformAttachment() == target && !slices.Contains(res, item) {
+				res = append(res, item)
+			}
+		}
+	}
+	return res
+}
+
+func appendUnique(items []int, others []int) []int {
+	for _, item := range others {
+		if !slices.Contains(items, item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,10 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"image/png"
+	"io"
+	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"testing/iotest"
 )
 
 func TestRotateAndScale(t *testing.T) {
@@ -33,3 +47,1501 @@ func TestAllMat(t *testing.T) {
 	m2[2] = 2323
 	fmt.Println(m)
 }
+
+// root -> target , root -> bone -> child
+func newTransformConstraintBones(item *TransformConstraint) []*BoneNode {
+	bones := []*Bone{
+		{Name: "root", Parent: -1, Scale: mgl32.Vec2{1, 1}},
+		{Name: "target", Parent: 0, Pos: mgl32.Vec2{50, 20}, Rotate: 30, Scale: mgl32.Vec2{1.5, 1.5}},
+		{Name: "bone", Parent: 0, Pos: mgl32.Vec2{0, 10}, Rotate: 10, Scale: mgl32.Vec2{2, 2}},
+		{Name: "child", Parent: 2, Pos: mgl32.Vec2{10, 0}, Scale: mgl32.Vec2{1, 1}},
+	}
+	item.Bones = []int{2}
+	item.Target = 1
+	nodes := NewBoneNodes(bones)
+	controller := NewConstraintController(nodes, nil, nil, []*TransformConstraintState{NewTransformConstraintState(item)})
+	controller.Update()
+	return nodes
+}
+
+func checkBoneWorld(t *testing.T, bone *BoneNode, mat2 mgl32.Mat2, pos mgl32.Vec2) {
+	if !bone.Mat2.ApproxEqualThreshold(mat2, 1e-4) || !bone.WorldPos.ApproxEqualThreshold(pos, 1e-3) {
+		t.Errorf("%s world = %v %v, want %v %v", bone.Bone.Name, bone.Mat2, bone.WorldPos, mat2, pos)
+	}
+}
+
+func checkChildWorld(t *testing.T, bones []*BoneNode) {
+	pos := bones[2].Mat2.Mul2x1(bones[3].Bone.Pos).Add(bones[2].WorldPos)
+	checkBoneWorld(t, bones[3], bones[2].Mat2, pos)
+}
+
+func TestTransformConstraintAbsoluteWorld(t *testing.T) {
+	bones := newTransformConstraintBones(&TransformConstraint{
+		RotateMix: 1, OffsetMix: 1, ScaleMix: 1, ShearMix: 1,
+	})
+	mat2 := GScaleMat.Mul2(Rotate(30)).Mul2(Scale(mgl32.Vec2{1.5, 1.5}))
+	checkBoneWorld(t, bones[2], mat2, GScaleMat.Mul2x1(mgl32.Vec2{50, 20}))
+	checkChildWorld(t, bones)
+}
+
+func TestTransformConstraintRelativeWorld(t *testing.T) {
+	bones := newTransformConstraintBones(&TransformConstraint{
+		Relative: true, RotateMix: 1,
+	})
+	mat2 := GScaleMat.Mul2(Rotate(40)).Mul2(Scale(mgl32.Vec2{2, 2}))
+	checkBoneWorld(t, bones[2], mat2, GScaleMat.Mul2x1(mgl32.Vec2{0, 10}))
+	checkChildWorld(t, bones)
+}
+
+// 缩放按移除 GScaleMat 后的世界缩放计算，target 缩放 1.5，bone 缩放 2
+func TestTransformConstraintWorldScale(t *testing.T) {
+	bones := newTransformConstraintBones(&TransformConstraint{
+		ScaleMix: 1, Scale: mgl32.Vec2{1, 1},
+	})
+	mat2 := GScaleMat.Mul2(Rotate(10)).Mul2(Scale(mgl32.Vec2{2.5, 2.5}))
+	checkBoneWorld(t, bones[2], mat2, GScaleMat.Mul2x1(mgl32.Vec2{0, 10}))
+	checkChildWorld(t, bones)
+	bones = newTransformConstraintBones(&TransformConstraint{
+		Relative: true, ScaleMix: 1,
+	})
+	mat2 = GScaleMat.Mul2(Rotate(10)).Mul2(Scale(mgl32.Vec2{3, 3}))
+	checkBoneWorld(t, bones[2], mat2, GScaleMat.Mul2x1(mgl32.Vec2{0, 10}))
+	checkChildWorld(t, bones)
+	bones = newTransformConstraintBones(&TransformConstraint{
+		Relative: true, ScaleMix: 0.5, Scale: mgl32.Vec2{0.5, 0},
+	})
+	mat2 = GScaleMat.Mul2(Rotate(10)).Mul2(Scale(mgl32.Vec2{3, 2.5}))
+	checkBoneWorld(t, bones[2], mat2, GScaleMat.Mul2x1(mgl32.Vec2{0, 10}))
+	checkChildWorld(t, bones)
+}
+
+func TestTransformConstraintAbsoluteLocal(t *testing.T) {
+	bones := newTransformConstraintBones(&TransformConstraint{
+		Local: true, Rotate: 5, Offset: mgl32.Vec2{3, 4}, Scale: mgl32.Vec2{0.5, 0.5},
+		RotateMix: 0.5, OffsetMix: 1, ScaleMix: 1,
+	})
+	mat2 := GScaleMat.Mul2(Rotate(22.5)).Mul2(Scale(mgl32.Vec2{2, 2}))
+	checkBoneWorld(t, bones[2], mat2, GScaleMat.Mul2x1(mgl32.Vec2{53, 24}))
+	checkChildWorld(t, bones)
+}
+
+func TestTransformConstraintRelativeLocal(t *testing.T) {
+	bones := newTransformConstraintBones(&TransformConstraint{
+		Local: true, Relative: true, Rotate: 5, Offset: mgl32.Vec2{2, 0},
+		RotateMix: 1, OffsetMix: 0.5, ScaleMix: 1,
+	})
+	mat2 := GScaleMat.Mul2(Rotate(45)).Mul2(Scale(mgl32.Vec2{3, 3}))
+	checkBoneWorld(t, bones[2], mat2, GScaleMat.Mul2x1(mgl32.Vec2{26, 20}))
+	checkChildWorld(t, bones)
+}
+
+// 局部模式混合值都为 0 时骨骼仍由约束计算世界数据，保持 setup 姿势
+func TestTransformConstraintLocalZeroMix(t *testing.T) {
+	for _, relative := range []bool{false, true} {
+		bones := newTransformConstraintBones(&TransformConstraint{
+			Local: true, Relative: relative, Rotate: 5, Offset: mgl32.Vec2{3, 4},
+		})
+		mat2 := GScaleMat.Mul2(Rotate(10)).Mul2(Scale(mgl32.Vec2{2, 2}))
+		checkBoneWorld(t, bones[2], mat2, GScaleMat.Mul2x1(mgl32.Vec2{0, 10}))
+		checkChildWorld(t, bones)
+	}
+}
+
+// 沿 x 轴长 300 的直线路径，3 个骨骼按百分比均匀分布
+func TestPathConstraintStraightLine(t *testing.T) {
+	bones := []*Bone{
+		{Name: "root", Parent: -1, Scale: mgl32.Vec2{1, 1}},
+		{Name: "b0", Parent: 0, Pos: mgl32.Vec2{0, 50}, Rotate: 45, Length: 100, Scale: mgl32.Vec2{1, 1}},
+		{Name: "b1", Parent: 1, Pos: mgl32.Vec2{100, 0}, Length: 100, Scale: mgl32.Vec2{1, 1}},
+		{Name: "b2", Parent: 2, Pos: mgl32.Vec2{100, 0}, Length: 100, Scale: mgl32.Vec2{1, 1}},
+	}
+	attachment := &Attachment{
+		Type:          AttachmentPath,
+		ConstantSpeed: true,
+		Vertices: []mgl32.Vec2{
+			{-100, 0}, {0, 0}, {100, 0}, // 入控制点 点 出控制点
+			{200, 0}, {300, 0}, {400, 0},
+		},
+		Lengths: []float32{300, 300},
+	}
+	item := NewPathConstraintState(&PathConstraint{
+		Bones: []int{1, 2, 3}, Target: 0,
+		PositionMode: PositionPercent, SpaceMode: SpacePercent, RotateMode: RotateTangent,
+		Space: 0.5, RotateMix: 1, OffsetMix: 1,
+	})
+	item.Attachment = attachment
+	nodes := NewBoneNodes(bones)
+	controller := NewConstraintController(nodes, nil, []*PathConstraintState{item}, nil)
+	controller.Update()
+	for i, bone := range nodes[1:] {
+		pos := GScaleMat.Mul2x1(mgl32.Vec2{float32(i) * 150, 0})
+		checkBoneWorld(t, bone, GScaleMat, pos)
+	}
+}
+
+// 父骨骼旋转 30 缩放 2，子骨骼局部旋转 10 斜切 (5, 20)
+func TestBoneTransformModes(t *testing.T) {
+	shear := mgl32.Vec2{5, 20}
+	tests := []struct {
+		mode uint8
+		mat2 mgl32.Mat2
+	}{
+		{TransformNormal, GScaleMat.Mul2(Rotate(30)).Mul2(Scale(mgl32.Vec2{2, 2})).Mul2(TransformMat2(10, mgl32.Vec2{1, 1}, shear))},
+		{TransformOnlyTranslation, GScaleMat.Mul2(TransformMat2(10, mgl32.Vec2{1, 1}, shear))},
+		{TransformNoRotationOrReflection, GScaleMat.Mul2(Scale(mgl32.Vec2{2, 2})).Mul2(TransformMat2(10, mgl32.Vec2{1, 1}, shear))},
+		{TransformNoScale, GScaleMat.Mul2(TransformMat2(40, mgl32.Vec2{1, 1}, shear))},
+		{TransformNoScaleOrReflection, GScaleMat.Mul2(TransformMat2(40, mgl32.Vec2{1, 1}, shear))},
+	}
+	for _, test := range tests {
+		nodes := NewBoneNodes([]*Bone{
+			{Name: "root", Parent: -1, Rotate: 30, Scale: mgl32.Vec2{2, 2}},
+			{Name: "bone", Parent: 0, Pos: mgl32.Vec2{10, 0}, Rotate: 10, Scale: mgl32.Vec2{1, 1},
+				Shear: shear, TransformMode: test.mode},
+		})
+		nodes[0].Update()
+		pos := GScaleMat.Mul2(Rotate(30)).Mul2x1(mgl32.Vec2{20, 0})
+		checkBoneWorld(t, nodes[1], test.mat2, pos)
+	}
+}
+
+func TestEventFireOnceAcrossLoop(t *testing.T) {
+	data := &EventData{Name: "hit"}
+	keyFrames := make([]*KeyFrame, 0)
+	for _, time := range []float32{0, 0.5, 1} {
+		keyFrames = append(keyFrames, &KeyFrame{Time: time, Event: &Event{Data: data, Time: time}})
+	}
+	anim := &Animation{Name: "loop", Duration: 1, Timelines: []*Timeline{{Type: TimelineEvent, KeyFrames: keyFrames}}}
+	controller := NewAnimController(anim, nil)
+	fired := make([]float32, 0)
+	controller.AddEventListener(func(event *Event) {
+		fired = append(fired, event.Time)
+	})
+	// 依次到 0.1 0.4 0.6 1.05，第二轮从 0.05 开始，到 0.3 0.7
+	for _, delta := range []float32{0.1, 0.3, 0.2, 0.45, 0.25, 0.4} {
+		controller.Update(delta)
+	}
+	want := []float32{0, 0.5, 1, 0, 0.5}
+	if fmt.Sprint(fired) != fmt.Sprint(want) {
+		t.Fatalf("fired %v want %v", fired, want)
+	}
+	// 跨过结尾回绕时，上一轮剩余的事件也要补上
+	fired = fired[:0]
+	controller.Update(0.5)
+	if fmt.Sprint(fired) != fmt.Sprint([]float32{1, 0}) {
+		t.Fatalf("fired %v", fired)
+	}
+}
+
+// 同一时间的事件按文件中的顺序触发，数量多时不稳定的排序会打乱顺序
+func TestEventSameTimeOrder(t *testing.T) {
+	keyFrames := make([]*KeyFrame, 0)
+	for i := 0; i < 100; i++ {
+		time := float32(0.5)
+		if i%10 == 0 {
+			time = 1 - float32(i)/200
+		}
+		keyFrames = append(keyFrames, &KeyFrame{Time: time, Event: &Event{Data: &EventData{Name: "hit"}, Int: i, Time: time}})
+	}
+	anim := newAnimation("events", []*Timeline{{Type: TimelineEvent, KeyFrames: keyFrames}})
+	pre := -1
+	for _, keyFrame := range anim.Timelines[0].KeyFrames {
+		if keyFrame.Time == 0.5 {
+			if keyFrame.Event.Int < pre {
+				t.Fatalf("event %d fired after %d", keyFrame.Event.Int, pre)
+			}
+			pre = keyFrame.Event.Int
+		}
+	}
+}
+
+func TestApplyBalance(t *testing.T) {
+	sound := make([]byte, 16) // 两帧 左右声道都是 1
+	for i := 0; i < 16; i += 4 {
+		binary.LittleEndian.PutUint32(sound[i:], math.Float32bits(1))
+	}
+	res := ApplyBalance(sound, 0.25)
+	want := []float32{0.75, 1, 0.75, 1}
+	for i, item := range want {
+		if val := math.Float32frombits(binary.LittleEndian.Uint32(res[i*4:])); val != item {
+			t.Fatalf("sample %d got %v want %v", i, val, item)
+		}
+	}
+}
+
+func TestParseSkins(t *testing.T) {
+	data := []byte{
+		0,    // 默认皮肤没有插槽
+		1,    // 一个命名皮肤
+		1,    // 名称 outfit
+		1, 2, // 依赖骨骼 2
+		0,    // ik
+		1, 0, // transform 0
+		0,    // path
+		1, 3, // 插槽 3
+		1, 2, 0, AttachmentClip, 4, // 附件 clip 结束插槽 4
+		1, 0, // 一个非权重顶点
+	}
+	data = binary.BigEndian.AppendUint32(data, math.Float32bits(1))
+	data = binary.BigEndian.AppendUint32(data, math.Float32bits(2))
+	skins := parseSkins(NewSkelReader(bytes.NewReader(data)), []string{"outfit", "clip"}, false)
+	// 空的默认皮肤保留在 skins[0]，但不计入文件中的皮肤索引
+	if len(skins) != 2 || skins[0].Name != "default" || len(skins[0].Attachments) != 0 || skinIndexOffset(skins) != 1 {
+		t.Fatalf("invalid default skin %v", skins)
+	}
+	skin := skins[1]
+	if skin.Name != "outfit" || fmt.Sprint(skin.Bones, skin.IkConstraints, skin.TransformConstraints, skin.PathConstraints) != "[2] [] [0] []" {
+		t.Fatalf("invalid skin %+v", skin)
+	}
+	attachment := skin.Attachments[0]
+	if attachment.Name != "clip" || attachment.Slot != 3 || attachment.EndSlot != 4 || attachment.Vertices[0] != (mgl32.Vec2{1, 2}) {
+		t.Fatalf("invalid attachment %+v", attachment)
+	}
+	// Deform 时间线的皮肤索引 0 指向 outfit
+	data = []byte{
+		5, 'a', 'n', 'i', 'm', // 名称
+		0, 0, 0, 0, 0, // slot bone ik transform path
+		1, 0, // 一个 deform 皮肤 0
+		1, 3, // 插槽 3
+		1, 1, // 附件 clip
+		1, 0, 0, 0, 0, 0, // 一帧 time 0 没有顶点
+		0, 0, // draw order event
+	}
+	animation := parseAnimation(NewSkelReader(bytes.NewReader(data)), []string{"clip"}, nil, skins, nil)
+	if timeline := animation.Timelines[0]; timeline.Type != TimelineDeform || timeline.Skin != 1 {
+		t.Fatalf("invalid deform timeline %+v", timeline)
+	}
+	writer := NewSkelWriter()
+	writeAnimation(writer, &SkeletonData{Skins: skins}, animation)
+	if !bytes.Equal(writer.Buffer.Bytes(), data) {
+		t.Fatalf("invalid deform skin index %v", writer.Buffer.Bytes())
+	}
+}
+
+func TestSkinComposition(t *testing.T) {
+	weapon := &Skin{Name: "weapon", Bones: []int{2}, Attachments: []*Attachment{
+		{Name: "weapon", RealName: "weapon/sword", Slot: 0},
+	}}
+	outfit := &Skin{Name: "outfit", Bones: []int{2}, IkConstraints: []int{0}, Attachments: []*Attachment{
+		{Name: "weapon", RealName: "outfit/stick", Slot: 0},
+		{Name: "body", RealName: "outfit/body", Slot: 1},
+	}}
+	skin := NewSkin("custom", outfit, weapon)
+	if fmt.Sprint(skin.Bones, skin.IkConstraints) != "[2] [0]" || len(skin.Attachments) != 2 {
+		t.Fatalf("invalid skin %+v", skin)
+	}
+	if skin.GetAttachment(0, "weapon").RealName != "weapon/sword" || skin.GetAttachment(1, "body") == nil {
+		t.Fatal("later skin should replace the same attachment")
+	}
+	// root -> bone -> skin bone(SkinRequire)，IK 也要求皮肤
+	bones := []*Bone{
+		{Name: "root", Parent: -1, Scale: mgl32.Vec2{1, 1}},
+		{Name: "bone", Parent: 0, Scale: mgl32.Vec2{1, 1}},
+		{Name: "skin", Parent: 1, Scale: mgl32.Vec2{1, 1}, SkinRequire: true},
+	}
+	ik := NewIkConstraintState(&IkConstraint{Bones: []int{2}, Target: 1, SkinRequire: true})
+	nodes := NewBoneNodes(bones)
+	controller := NewConstraintController(nodes, []*IkConstraintState{ik}, nil, nil)
+	if nodes[2].Active || ik.Active || len(controller.UpdateCache) != 2 {
+		t.Fatalf("skin bone should be inactive without skin %v", controller.UpdateCache)
+	}
+	controller.SetSkin(weapon)
+	if !nodes[2].Active || ik.Active || len(controller.UpdateCache) != 3 {
+		t.Fatal("skin bone should be active with weapon skin")
+	}
+	controller.SetSkin(skin)
+	if !nodes[2].Active || !ik.Active || len(controller.UpdateCache) != 4 {
+		t.Fatal("ik should be active with custom skin")
+	}
+}
+
+func TestParseNonessential(t *testing.T) {
+	f4 := func(data []byte, values ...float32) []byte {
+		for _, value := range values {
+			data = binary.BigEndian.AppendUint32(data, math.Float32bits(value))
+		}
+		return data
+	}
+	data := []byte{3, 'a', 'b', 4, '3', '.', '8'} // 字符串长度 +1
+	data = f4(data, 1, 2, 3, 4)
+	data = append(data, 1) // nonessential
+	data = f4(data, 30)
+	data = append(data, 8, '.', '/', 'i', 'm', 'a', 'g', 'e', 0) // images 路径 audio 路径为空
+	header := parseSkelHeader(NewSkelReader(bytes.NewReader(data)))
+	if !header.Nonessential || header.Fps != 30 || header.ImagesPath != "./image" || header.AudioPath != "" || header.Size != (mgl32.Vec2{3, 4}) {
+		t.Fatalf("invalid header %+v", header)
+	}
+	data = []byte{1, 0, AttachmentPoint}
+	data = f4(data, 90, 5, 6)
+	data = append(data, 0xFF, 0, 0, 0xFF)
+	point := parseAttachment(NewSkelReader(bytes.NewReader(data)), 2, []string{"muzzle"}, true)
+	if point.Name != "muzzle" || point.Rotate != 90 || point.Pos != (mgl32.Vec2{5, 6}) || point.Color != (mgl32.Vec4{1, 0, 0, 1}) {
+		t.Fatalf("invalid point %+v", point)
+	}
+}
+
+func TestLinkMeshInheritDeform(t *testing.T) {
+	parent := &Attachment{Name: "body", Slot: 1, Type: AttachmentMesh,
+		Vertices: []mgl32.Vec2{{0, 0}, {1, 0}, {0, 1}}, UVs: []mgl32.Vec2{{0, 0}, {1, 0}, {0, 1}}, Indices: []uint16{0, 1, 2}}
+	inherit := &Attachment{Name: "body", Slot: 1, Type: AttachmentLinkMesh, ParentName: "body", InheritDeform: true}
+	own := &Attachment{Name: "body", Slot: 1, Type: AttachmentLinkMesh, ParentSkin: "red", ParentName: "body"}
+	data := &SkeletonData{Skins: []*Skin{
+		{Name: "default", Attachments: []*Attachment{parent}},
+		{Name: "red", Attachments: []*Attachment{inherit}},
+		{Name: "blue", Attachments: []*Attachment{own}},
+	}}
+	linkMeshes(data.Skins)
+	if inherit.Parent != parent || own.Parent != inherit || len(own.UVs) != 3 || len(own.Indices) != 3 {
+		t.Fatal("linked mesh should share the parent mesh")
+	}
+	attachments := data.GetDeformAttachments(parent)
+	if len(attachments) != 2 || attachments[0] != parent || attachments[1] != inherit {
+		t.Fatalf("invalid deform attachments %v", attachments)
+	}
+	skeleton := &Skeleton{Data: data, Deforms: make(map[*Attachment]*AttachmentState)}
+	deforms := make([]*AttachmentState, 0)
+	for _, item := range attachments {
+		deforms = append(deforms, skeleton.GetDeform(item))
+	}
+	deform := []mgl32.Vec2{{1, 1}, {1, 1}, {1, 1}}
+	update := NewDeformAnimUpdate(parent, deforms, []*KeyFrame{{Time: 0, Deform: deform}})
+	update.Update(0)
+	if skeleton.GetDeform(inherit).CurrVertices[0] != (mgl32.Vec2{1, 1}) || skeleton.GetDeform(own).CurrVertices[0] != (mgl32.Vec2{}) {
+		t.Fatal("only the inherit deform linked mesh should follow the parent")
+	}
+}
+
+func TestSkeletonBounds(t *testing.T) {
+	// 凹多边形 缺口在上方
+	polygon := []mgl32.Vec2{{0, 0}, {4, 0}, {4, 4}, {3, 4}, {3, 1}, {1, 1}, {1, 4}, {0, 4}}
+	if !PolygonContainsPoint(polygon, mgl32.Vec2{0.5, 3}) || PolygonContainsPoint(polygon, mgl32.Vec2{2, 3}) {
+		t.Fatal("invalid point in concave polygon")
+	}
+	if !PolygonIntersectsSegment(polygon, mgl32.Vec2{2, 3}, mgl32.Vec2{2, 0.5}) || PolygonIntersectsSegment(polygon, mgl32.Vec2{2, 3}, mgl32.Vec2{2, 2}) {
+		t.Fatal("invalid segment intersection")
+	}
+	box := &Attachment{Name: "hitbox", Type: AttachmentBoundBox, Vertices: polygon}
+	skeleton := NewSkeleton(&SkeletonData{
+		Bones: []*Bone{{Name: "root", Parent: -1, Scale: mgl32.Vec2{1, 1}, Pos: mgl32.Vec2{10, 0}}},
+		Slots: []*Slot{{Name: "hitbox", Bone: 0, Attachment: "hitbox"}},
+		Skin:  &Skin{Name: "default", Attachments: []*Attachment{box}},
+	})
+	skeleton.UpdateWorldTransform()
+	bounds := &SkeletonBounds{}
+	bounds.Update(skeleton)
+	// 根骨骼带全局缩放 GScaleMat
+	point := GScaleMat.Mul2x1(mgl32.Vec2{0.5, 3}).Add(mgl32.Vec2{10, 0})
+	if !bounds.AabbContainsPoint(point) || bounds.ContainsPoint(point) == nil || bounds.ContainsPoint(point).Attachment != box {
+		t.Fatalf("point should hit the bounding box %v %v", bounds.Min, bounds.Max)
+	}
+	if !bounds.AabbIntersectsSegment(mgl32.Vec2{0, 0}, mgl32.Vec2{20, -1}) || bounds.AabbIntersectsSegment(mgl32.Vec2{0, 1}, mgl32.Vec2{20, 1}) {
+		t.Fatal("invalid aabb segment intersection")
+	}
+}
+
+func TestPointAttachmentWorld(t *testing.T) {
+	bones := NewBoneNodes([]*Bone{
+		{Name: "root", Parent: -1, Scale: mgl32.Vec2{1, 1}},
+		{Name: "gun", Parent: 0, Pos: mgl32.Vec2{10, 0}, Rotate: 90, Scale: mgl32.Vec2{2, 2}},
+	})
+	bones[0].Update()
+	point := &Attachment{Name: "muzzle", Type: AttachmentPoint, Pos: mgl32.Vec2{5, 0}, Rotate: 45}
+	// 骨骼坐标系下 (5,0) 旋转 90 度缩放 2 倍后为 (0,10)，再经过全局缩放
+	pos := point.ComputeWorldPosition(bones[1])
+	if !pos.ApproxEqualThreshold(GScaleMat.Mul2x1(mgl32.Vec2{10, 10}), 1e-4) {
+		t.Fatalf("invalid position %v", pos)
+	}
+	// y 轴翻转后 135 度变为 -135 度
+	if rotate := point.ComputeWorldRotation(bones[1]); math.Abs(float64(rotate+135)) > 1e-3 {
+		t.Fatalf("invalid rotation %v", rotate)
+	}
+}
+
+func TestClipTrianglesConcave(t *testing.T) {
+	// L 形凹多边形，面积为 3
+	polygon := []mgl32.Vec2{{0, 0}, {2, 0}, {2, 1}, {1, 1}, {1, 2}, {0, 2}}
+	clipper := &SkeletonClipping{}
+	clipper.ClipStart(&Attachment{Type: AttachmentClip, EndSlot: 1}, polygon)
+	if !clipper.IsClipping() || len(clipper.Polygons) < 2 {
+		t.Fatalf("concave polygon should decompose into convex polygons %v", clipper.Polygons)
+	}
+	// 覆盖 (0,0)-(2,2) 的正方形，UV 为坐标的 10 倍
+	square := []mgl32.Vec2{{0, 0}, {2, 0}, {2, 2}, {0, 2}}
+	uvs := []mgl32.Vec2{{0, 0}, {20, 0}, {20, 20}, {0, 20}}
+	vertices, resUVs, indices := clipper.ClipTriangles(square, uvs, []uint16{0, 1, 2, 0, 2, 3})
+	area := float32(0)
+	for i := 0; i < len(indices); i += 3 {
+		p1, p2, p3 := vertices[indices[i]], vertices[indices[i+1]], vertices[indices[i+2]]
+		area += float32(math.Abs(float64((p2.X()-p1.X())*(p3.Y()-p1.Y())-(p3.X()-p1.X())*(p2.Y()-p1.Y())))) / 2
+	}
+	if math.Abs(float64(area-3)) > 1e-4 {
+		t.Fatalf("invalid clipped area %v", area)
+	}
+	for i, vertex := range vertices {
+		if !resUVs[i].ApproxEqualThreshold(vertex.Mul(10), 1e-3) {
+			t.Fatalf("invalid uv %v at %v", resUVs[i], vertex)
+		}
+	}
+	clipper.ClipEnd(&Slot{Index: 0})
+	if !clipper.IsClipping() {
+		t.Fatal("clipping should last until the end slot")
+	}
+	clipper.ClipEnd(&Slot{Index: 1})
+	if clipper.IsClipping() {
+		t.Fatal("clipping should end at the end slot")
+	}
+}
+
+func TestLoadSkelError(t *testing.T) {
+	bs, err := os.ReadFile("res/003_kalts/build_char_003_kalts.skel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	skel, err := LoadSkel(bytes.NewReader(bs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每次只返回一个字节的 reader 也要完整读取
+	if temp, err := LoadSkel(iotest.OneByteReader(bytes.NewReader(bs))); err != nil || len(temp.Animations) != len(skel.Animations) {
+		t.Fatalf("short read failed %v", err)
+	}
+	// 截断的数据返回 ParseError 而不是 panic
+	_, err = LoadSkel(bytes.NewReader(bs[:len(bs)/2]))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Offset <= 0 || parseErr.Offset > int64(len(bs)/2) {
+		t.Fatalf("invalid error %v", err)
+	}
+	if !strings.HasPrefix(parseErr.Section, "animation:") && !strings.HasPrefix(parseErr.Section, "skin:") {
+		t.Fatalf("invalid section %s", parseErr.Section)
+	}
+	_, err = LoadAtlas(strings.NewReader("\nimage.png\nsize: 1,1\nformat: RGBA8888\nfilter: Linear,Linear\nrepeat: none\n" +
+		"item\n  rotate: false\n  xy: a, 0\n  size: 1, 1\n  orig: 1, 1\n  offset: 0, 0\n  index: -1\n"))
+	if !errors.As(err, &parseErr) || parseErr.Section != "region:item" || parseErr.Offset != 73 {
+		t.Fatalf("invalid atlas error %v", err)
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	skel, err := os.ReadFile("res/003_kalts/build_char_003_kalts.skel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := bytes.NewBuffer(nil)
+	if err = png.Encode(img, image.NewRGBA(image.Rect(0, 0, 2, 1))); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		"model/a.skel":   {Data: skel},
+		"model/a.atlas":  {Data: []byte("\npage.png\nsize: 2,1\nformat: RGBA8888\nfilter: Linear,Linear\nrepeat: none\n")},
+		"model/page.png": {Data: img.Bytes()},
+	}
+	if _, err = LoadSkelFS(fsys, "model/a.skel"); err != nil {
+		t.Fatal(err)
+	}
+	atlas, err := LoadAtlasFS(fsys, "model/a.atlas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 图片相对于 atlas 文件
+	if atlas.Image != "model/page.png" {
+		t.Fatalf("invalid image path %s", atlas.Image)
+	}
+	page, err := atlas.LoadImage()
+	if err != nil || page.Bounds().Dx() != 2 {
+		t.Fatalf("load image failed %v", err)
+	}
+}
+
+// 每次最多从文件读取 4 字节，模拟逐字段读取文件的无缓冲读取
+type fieldReader struct {
+	Reader io.Reader
+}
+
+func (r *fieldReader) Read(bs []byte) (int, error) {
+	return r.Reader.Read(bs[:min(len(bs), 4)])
+}
+
+// unbuffered 作为对照，每个字段都有一次系统调用
+func BenchmarkLoadSkel(b *testing.B) {
+	paths, err := filepath.Glob("res/*/*.skel")
+	if err != nil || len(paths) == 0 {
+		b.Skip("no skel in res")
+	}
+	readers := []struct {
+		name string
+		wrap func(io.Reader) io.Reader
+	}{
+		{"unbuffered", func(r io.Reader) io.Reader { return &fieldReader{Reader: r} }},
+		{"buffered", func(r io.Reader) io.Reader { return r }},
+	}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, reader := range readers {
+			b.Run(filepath.Base(path)+"/"+reader.name, func(b *testing.B) {
+				b.SetBytes(info.Size())
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					file, err := os.Open(path) // 直接读文件，包含系统调用的开销
+					if err != nil {
+						b.Fatal(err)
+					}
+					if _, err = LoadSkel(reader.wrap(file)); err != nil {
+						b.Fatal(err)
+					}
+					file.Close()
+				}
+			})
+		}
+	}
+}
+
+func TestLoadSkelJson(t *testing.T) {
+	data := `{
+	"skeleton": {"hash": "h", "spine": "3.8.99", "width": 10, "height": 20},
+	"bones": [{"name": "root"}, {"name": "arm", "parent": "root", "rotation": 30, "x": 5, "scaleY": 2}],
+	"slots": [{"name": "body", "bone": "root", "attachment": "body"}, {"name": "hand", "bone": "arm", "dark": "ff0000", "blend": "additive"}],
+	"ik": [{"name": "ik", "order": 1, "bones": ["arm"], "target": "root", "bendPositive": false}],
+	"transform": [{"name": "tc", "order": 0, "bones": ["arm"], "target": "root", "translateMix": 0.5}],
+	"skins": [{"name": "default", "attachments": {
+		"body": {"body": {"type": "mesh", "uvs": [0, 0, 1, 0, 1, 1], "triangles": [0, 1, 2], "vertices": [0, 0, 1, 0, 1, 1], "hull": 3},
+			"body2": {"type": "linkedmesh", "parent": "body", "deform": false}},
+		"hand": {"hand": {"x": 1, "width": 4, "height": 4, "color": "ff000080"}}
+	}}],
+	"events": {"hit": {"int": 3, "string": "s"}},
+	"animations": {
+		"walk": {
+			"slots": {"hand": {"attachment": [{"name": "hand"}, {"time": 1, "name": null}]}},
+			"bones": {"arm": {
+				"rotate": [{"angle": 10, "curve": "stepped"}, {"time": 0.5, "angle": 20, "curve": 0.25, "c3": 0.75}, {"time": 1}],
+				"scale": [{"curve": [0.1, 0.2, 0.3, 0.4]}, {"time": 2, "x": 3}]
+			}},
+			"ik": {"ik": [{"mix": 0.5}]},
+			"deform": {"default": {"body": {"body": [{"time": 0.5, "offset": 3, "vertices": [7, 8]}]}}},
+			"drawOrder": [{"time": 0.5, "offsets": [{"slot": "body", "offset": 1}]}],
+			"events": [{"time": 0.2, "name": "hit", "float": 1.5}]
+		},
+		"idle": {}
+	}
+}`
+	skel, err := LoadSkelJson(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skel.Bones[1].Parent != 0 || skel.Bones[1].Scale != (mgl32.Vec2{1, 2}) || skel.Slots[1].BlendMode != BlendAdditive {
+		t.Fatal("invalid bones or slots")
+	}
+	// 约束保持文件中的顺序，不按 order 排序
+	if skel.TransformConstraints[0].OffsetMix != 0.5 || skel.IkConstraints[0].BendDirection != -1 {
+		t.Fatal("invalid constraints")
+	}
+	body := skel.Skin.GetAttachment(0, "body2")
+	if body.Parent == nil || len(body.UVs) != 3 || body.GetDeformAttachment() != body {
+		t.Fatal("invalid linked mesh")
+	}
+	if hand := skel.Skin.GetAttachment(1, "hand"); hand.Path != "hand" || hand.Color[3] != 128.0/255 || hand.Scale != (mgl32.Vec2{1, 1}) {
+		t.Fatal("invalid region")
+	}
+	if len(skel.Animations) != 2 || skel.Animations[0].Name != "walk" || skel.Animations[0].Duration != 2 {
+		t.Fatal("animations should keep the file order")
+	}
+	timelines := skel.Animations[0].Timelines
+	if timelines[0].KeyFrames[1].Attachment != "" || timelines[0].KeyFrames[0].Curve != nil {
+		t.Fatal("invalid attachment timeline")
+	}
+	rotate := timelines[1].KeyFrames
+	if rotate[0].Curve.Type != CurveStepped || rotate[1].Curve.Data != [2]mgl32.Vec2{{0.25, 0}, {0.75, 1}} || rotate[2].Curve != nil || rotate[2].Rotate != 0 {
+		t.Fatal("invalid rotate timeline")
+	}
+	if scale := timelines[2].KeyFrames; scale[0].Curve.Data[1] != (mgl32.Vec2{0.3, 0.4}) || scale[1].Scale != (mgl32.Vec2{3, 1}) {
+		t.Fatal("invalid scale timeline")
+	}
+	if ik := timelines[3].KeyFrames[0]; ik.Mix != 0.5 || ik.BendDirection != 1 {
+		t.Fatal("invalid ik timeline")
+	}
+	if deform := timelines[4].KeyFrames[0].Deform; deform[1] != (mgl32.Vec2{0, 7}) || deform[2] != (mgl32.Vec2{8, 0}) {
+		t.Fatal("invalid deform timeline")
+	}
+	if drawOrder := timelines[5].KeyFrames[0].DrawOrder; drawOrder[0] != 1 || drawOrder[1] != 0 {
+		t.Fatal("invalid draw order timeline")
+	}
+	if event := timelines[6].KeyFrames[0].Event; event.Int != 3 || event.Float != 1.5 || event.String != "s" {
+		t.Fatal("invalid event timeline")
+	}
+	_, err = LoadSkelJson(strings.NewReader(`{"bones": [{"name": "root"}], "slots": [{"name": "a", "bone": "none"}]}`))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Section != "slots" {
+		t.Fatalf("invalid error %v", err)
+	}
+}
+
+func TestSaveSkelJson(t *testing.T) {
+	files, err := filepath.Glob("res/*/*.skel")
+	if err != nil || len(files) == 0 {
+		t.Fatal("no skel in res", err)
+	}
+	for _, file := range files {
+		data := &bytes.Buffer{}
+		if err = SaveSkelJson(data, ParseSkel(file)); err != nil {
+			t.Fatal(file, err)
+		}
+		skel, err := LoadSkelJson(bytes.NewReader(data.Bytes()))
+		if err != nil {
+			t.Fatal(file, err)
+		}
+		// 重新导入后再导出应完全一致，DrawOrder 的偏移与曲线都能还原
+		res := &bytes.Buffer{}
+		if err = SaveSkelJson(res, skel); err != nil {
+			t.Fatal(file, err)
+		}
+		if !bytes.Equal(data.Bytes(), res.Bytes()) {
+			t.Fatal(file, "json round trip mismatch")
+		}
+	}
+}
+
+func TestSaveSkel(t *testing.T) {
+	files, err := filepath.Glob("res/*/*.skel")
+	if err != nil || len(files) == 0 {
+		t.Fatal("no skel in res", err)
+	}
+	writer := NewSkelWriter()
+	writeStr(writer, "根骨骼")
+	if bs := writer.Buffer.Bytes(); bs[0] != 10 || len(bs) != 10 { // 9 字节 +1
+		t.Fatalf("invalid string prefix %v", bs)
+	}
+	if res := readStr(NewSkelReader(bytes.NewReader(writer.Buffer.Bytes()))); res != "根骨骼" {
+		t.Fatalf("invalid string %s", res)
+	}
+	for _, file := range files {
+		skel := ParseSkel(file)
+		skel.Bones[0].Name = "根骨骼" // 字符串长度为 utf8 字节数
+		data := &bytes.Buffer{}
+		if err = SaveSkel(data, skel); err != nil {
+			t.Fatal(file, err)
+		}
+		res, err := LoadSkel(bytes.NewReader(data.Bytes()))
+		if err != nil {
+			t.Fatal(file, err)
+		}
+		if !reflect.DeepEqual(skel, res) {
+			t.Fatal(file, "skel round trip mismatch")
+		}
+	}
+}
+
+func TestLoadSkelVersions(t *testing.T) {
+	// 3.7 没有 x y、字符串表、SkinRequire 与 IK 的 softness
+	w := NewSkelWriter()
+	writeStr(w, "hash")
+	writeStr(w, "3.7.94")
+	writeVec2s(w, mgl32.Vec2{10, 20})
+	writeBool(w, false)
+	writeInt(w, 1) // bones
+	writeStr(w, "root")
+	writeF4(w, 0)
+	writeVec2s(w, mgl32.Vec2{}, mgl32.Vec2{1, 1}, mgl32.Vec2{})
+	writeF4(w, 0)
+	writeU8(w, TransformNormal)
+	writeInt(w, 1) // slots
+	writeStr(w, "body")
+	writeInt(w, 0)
+	writeClr(w, mgl32.Vec4{1, 1, 1, 1})
+	writeClr(w, mgl32.Vec4{1, 1, 1, 1})
+	writeStr(w, "body")
+	writeU8(w, BlendNormal)
+	writeInt(w, 1) // ik
+	writeStr(w, "ik")
+	writeInt(w, 0)
+	writeInts(w, []int{0})
+	writeInt(w, 0)
+	writeF4(w, 0.5)
+	writeU8(w, 1)
+	writeBool(w, false)
+	writeBool(w, false)
+	writeBool(w, false)
+	writeInt(w, 0) // transform
+	writeInt(w, 0) // path
+	writeInt(w, 1) // 默认皮肤
+	writeInt(w, 0)
+	writeInt(w, 1)
+	writeStr(w, "body")
+	writeStr(w, "")
+	writeU8(w, AttachmentRegion)
+	writeStr(w, "")
+	writeF4(w, 0)
+	writeVec2s(w, mgl32.Vec2{}, mgl32.Vec2{1, 1}, mgl32.Vec2{4, 4})
+	writeClr(w, mgl32.Vec4{1, 1, 1, 1})
+	writeInt(w, 0) // skins
+	writeInt(w, 0) // events
+	writeInt(w, 1) // animations
+	writeStr(w, "idle")
+	writeInt(w, 0)
+	writeInt(w, 0)
+	writeInt(w, 1) // ik
+	writeInt(w, 0)
+	writeInt(w, 1)
+	writeF4(w, 2)
+	writeF4(w, 0.25)
+	writeU8(w, 0xFF)
+	writeBool(w, true)
+	writeBool(w, false)
+	for i := 0; i < 5; i++ {
+		writeInt(w, 0) // transform path deform drawOrder event
+	}
+	skel, err := LoadSkel(bytes.NewReader(w.Buffer.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skel.Header.Size != (mgl32.Vec2{10, 20}) || skel.Skin.GetAttachment(0, "body").Path != "body" || skel.IkConstraints[0].Mix != 0.5 {
+		t.Fatal("invalid 3.7 setup pose")
+	}
+	if ik := skel.Animations[0].Timelines[0].KeyFrames[0]; ik.Mix != 0.25 || ik.BendDirection != -1 || !ik.Compress || skel.Animations[0].Duration != 2 {
+		t.Fatal("invalid 3.7 animation")
+	}
+
+	// 4.1 的 hash 为整数，每个分量有自己的曲线，曲线在下一帧的数值之后，附件可以有序列帧
+	w = NewSkelWriter()
+	w.Buffer.Write([]byte{0, 0, 0, 0, 0, 0, 0, 42})
+	writeStr(w, "4.1.24")
+	writeVec2s(w, mgl32.Vec2{1, 2}, mgl32.Vec2{10, 20})
+	writeBool(w, false)
+	writeStrings(w, []string{"body"})
+	writeInt(w, 1) // bones
+	writeStr(w, "root")
+	writeF4(w, 0)
+	writeVec2s(w, mgl32.Vec2{}, mgl32.Vec2{1, 1}, mgl32.Vec2{})
+	writeF4(w, 0)
+	writeU8(w, TransformNormal)
+	writeBool(w, false)
+	writeInt(w, 1) // slots
+	writeStr(w, "body")
+	writeInt(w, 0)
+	writeClr(w, mgl32.Vec4{1, 1, 1, 1})
+	writeClr(w, mgl32.Vec4{1, 1, 1, 1})
+	writeInt(w, 1)
+	writeU8(w, BlendNormal)
+	writeInt(w, 0) // ik
+	writeInt(w, 0) // transform
+	writeInt(w, 0) // path
+	writeInt(w, 1) // 默认皮肤
+	writeInt(w, 0)
+	writeInt(w, 1)
+	writeInt(w, 1)
+	writeInt(w, 0)
+	writeU8(w, AttachmentRegion)
+	writeInt(w, 0)
+	writeF4(w, 0)
+	writeVec2s(w, mgl32.Vec2{}, mgl32.Vec2{1, 1}, mgl32.Vec2{4, 4})
+	writeClr(w, mgl32.Vec4{1, 1, 1, 1})
+	writeBool(w, true) // 序列帧
+	writeInt(w, 3)     // 帧数
+	writeInt(w, 1)     // 起始编号
+	writeInt(w, 2)     // 位数
+	writeInt(w, 2)     // setup 帧
+	writeInt(w, 0)     // skins
+	writeInt(w, 0)     // events
+	writeInt(w, 1)     // animations
+	writeStr(w, "run")
+	writeInt(w, 4)
+	writeInt(w, 1) // slot
+	writeInt(w, 0)
+	writeInt(w, 2)
+	writeU8(w, Slot4Rgb)
+	writeInt(w, 2)
+	writeInt(w, 0)
+	writeF4(w, 0)
+	w.Buffer.Write([]byte{0xFF, 0, 0})
+	writeF4(w, 1)
+	w.Buffer.Write([]byte{0, 0, 0xFF})
+	writeU8(w, CurveLinear)
+	writeU8(w, Slot4Alpha)
+	writeInt(w, 2)
+	writeInt(w, 0)
+	writeF4(w, 0.5)
+	writeU8(w, 0xFF)
+	writeF4(w, 1)
+	writeU8(w, 0)
+	writeU8(w, CurveLinear)
+	writeInt(w, 1) // bone
+	writeInt(w, 0)
+	writeInt(w, 2)
+	writeU8(w, Bone4TranslateX)
+	writeInt(w, 2)
+	writeInt(w, 1)
+	writeF4(w, 0)
+	writeF4(w, 0)
+	writeF4(w, 1)
+	writeF4(w, 10)
+	writeU8(w, CurveBezier)
+	writeVec2s(w, mgl32.Vec2{0.25, 0}, mgl32.Vec2{0.75, 10})
+	writeU8(w, Bone4TranslateY)
+	writeInt(w, 1)
+	writeInt(w, 0)
+	writeF4(w, 0)
+	writeF4(w, 5)
+	writeInt(w, 0) // ik
+	writeInt(w, 0) // transform
+	writeInt(w, 0) // path
+	writeInt(w, 1) // 附件时间线
+	writeInt(w, 0)
+	writeInt(w, 1)
+	writeInt(w, 0)
+	writeInt(w, 1)
+	writeInt(w, 1)
+	writeU8(w, Attachment4Sequence)
+	writeInt(w, 1)
+	writeF4(w, 0)
+	w.Buffer.Write([]byte{0, 0, 0, 0x10})
+	writeF4(w, 0.1)
+	writeInt(w, 0) // drawOrder
+	writeInt(w, 0) // event
+	skel, err = LoadSkel(bytes.NewReader(w.Buffer.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skel.Header.Hash != "42" || skel.Header.Pos != (mgl32.Vec2{1, 2}) || skel.Skin.GetAttachment(0, "body").Path != "body03" {
+		t.Fatal("invalid 4.1 setup pose")
+	}
+	timelines := skel.Animations[0].Timelines
+	if len(timelines) != 2 || timelines[0].Type != TimelineColor || timelines[1].Type != TimelineTranslate {
+		t.Fatal("split timelines should be merged")
+	}
+	// rgb 与 alpha 按两条时间线所有关键帧的时间重新采样
+	color := timelines[0].KeyFrames
+	if len(color) != 3 || color[1].Color != (mgl32.Vec4{0.5, 0, 0.5, 1}) || color[2].Color != (mgl32.Vec4{0, 0, 1, 0}) {
+		t.Fatal("invalid color timeline")
+	}
+	translate := timelines[1].KeyFrames
+	if translate[0].Curve.Data != [2]mgl32.Vec2{{0.25, 0}, {0.75, 1}} || translate[1].Offset != (mgl32.Vec2{10, 5}) || translate[1].Curve != nil {
+		t.Fatal("invalid translate timeline")
+	}
+
+	for _, version := range []string{"3.6.53", "4.2.11"} {
+		w = NewSkelWriter()
+		if version[0] == '4' {
+			w.Buffer.Write(make([]byte, 8))
+		} else {
+			writeStr(w, "hash")
+		}
+		writeStr(w, version)
+		if _, err = LoadSkel(bytes.NewReader(w.Buffer.Bytes())); err == nil || !strings.Contains(err.Error(), "unsupported spine version") {
+			t.Fatal("should reject", version, err)
+		}
+	}
+}
+
+// 同一份数据的两个实例，互相不影响
+func TestSkeletonInstances(t *testing.T) {
+	data := ParseSkel("res/003_kalts/build_char_003_kalts.skel")
+	anim := data.Animations[0]
+	for _, item := range data.Animations {
+		if item.Duration > anim.Duration {
+			anim = item
+		}
+	}
+	pose := func(skeleton *Skeleton, controller *AnimController, curr float32) string {
+		skeleton.SetToSetupPose()
+		for _, update := range controller.AnimUpdates {
+			update.Update(curr)
+		}
+		skeleton.UpdateWorldTransform()
+		return skeletonPose(skeleton)
+	}
+	a, b := NewSkeleton(data), NewSkeleton(data)
+	animA, animB := NewAnimController(anim, a), NewAnimController(anim, b)
+	start := pose(a, animA, 0)
+	middle := pose(b, animB, anim.Duration/2)
+	if skeletonPose(a) != start {
+		t.Fatal("updating one skeleton should not change another")
+	}
+	if start == middle || pose(a, animA, anim.Duration/2) != middle {
+		t.Fatalf("skeletons from the same data should have the same pose at %v", anim.Duration/2)
+	}
+	if skeletonPose(NewSkeleton(data)) != skeletonPose(NewSkeleton(data)) {
+		t.Fatal("setup pose should not be modified by animation")
+	}
+}
+
+// 骨骼的世界数据与插槽的附件、颜色、顶点
+func skeletonPose(skeleton *Skeleton) string {
+	res := &strings.Builder{}
+	for _, bone := range skeleton.Bones {
+		fmt.Fprintln(res, bone.WorldPos, bone.Mat2)
+	}
+	for _, slot := range skeleton.DrawOrder {
+		fmt.Fprintln(res, slot.Slot.Name, slot.CurrAttachment, slot.CurrColor, slot.CurrDarkColor)
+		if attachment := skeleton.GetSlotAttachment(slot); attachment != nil && len(attachment.UVs) > 0 {
+			fmt.Fprintln(res, skeleton.ComputeWorldVertices(attachment, slot.Slot.Bone))
+		}
+	}
+	return res.String()
+}
+
+// 并行更新的结果与逐个更新一致
+func TestSkeletonSet(t *testing.T) {
+	data := ParseSkel("res/003_kalts/build_char_003_kalts.skel")
+	newSet := func(workers int) *SkeletonSet {
+		res := NewSkeletonSet(workers)
+		for i := 0; i < 32; i++ {
+			instance := NewSkeletonInstance(NewSkeleton(data), data.Animations[i%len(data.Animations)])
+			instance.Skeleton.Pos = mgl32.Vec2{float32(i * 10), 0}
+			instance.AnimController.SetTime(float32(i) * 0.1) // 错开播放进度
+			res.Add(instance)
+		}
+		return res
+	}
+	serial, parallel := newSet(1), newSet(8)
+	for frame := 1; frame <= 10; frame++ {
+		serial.Update(0.2)
+		parallel.Update(0.2)
+		for i := range serial.Instances {
+			if skeletonPose(serial.Instances[i].Skeleton) != skeletonPose(parallel.Instances[i].Skeleton) {
+				t.Fatalf("instance %d differs at frame %d", i, frame)
+			}
+		}
+	}
+}
+
+func BenchmarkSkeletonSet(b *testing.B) {
+	data := ParseSkel("res/003_kalts/build_char_003_kalts.skel")
+	const count = 64
+	workerCounts := []int{1}
+	if procs := runtime.GOMAXPROCS(0); procs > 1 {
+		workerCounts = append(workerCounts, procs)
+	}
+	for _, workers := range workerCounts {
+		set := NewSkeletonSet(workers)
+		for i := 0; i < count; i++ {
+			set.Add(NewSkeletonInstance(NewSkeleton(data), data.Animations[i%len(data.Animations)]))
+		}
+		b.Run(fmt.Sprintf("%d-instances-%d-workers", count, workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				set.Update(1.0 / 60)
+			}
+		})
+	}
+}
+
+// 按 delta 推进时的循环、倒放、暂停、跳转、往返与只播放一次
+func TestAnimControllerPlayback(t *testing.T) {
+	keyFrames := make([]*KeyFrame, 0)
+	for _, time := range []float32{0, 0.5, 1} {
+		keyFrames = append(keyFrames, &KeyFrame{Time: time, Event: &Event{Data: &EventData{Name: "hit"}, Time: time}})
+	}
+	anim := &Animation{Name: "test", Duration: 1, Timelines: []*Timeline{{Type: TimelineEvent, KeyFrames: keyFrames}}}
+	controller := NewAnimController(anim, nil) // 只有事件不需要骨骼
+	fired := make([]float32, 0)
+	controller.AddEventListener(func(event *Event) {
+		fired = append(fired, event.Time)
+	})
+	check := func(name string, curr float32, want ...float32) {
+		if math.Abs(float64(controller.Time-curr)) > 1e-5 || fmt.Sprint(fired) != fmt.Sprint(want) {
+			t.Fatalf("%s: time %v fired %v, want %v %v", name, controller.Time, fired, curr, want)
+		}
+		fired = fired[:0]
+	}
+	for i := 0; i < 3; i++ {
+		controller.Update(0.4)
+	}
+	check("loop", 0.2, 0, 0.5, 1, 0)
+	controller.TimeScale = -1
+	controller.Update(0.3)
+	check("reverse", 0.9, 0, 1)
+	controller.Paused = true
+	controller.Update(1)
+	check("pause", 0.9)
+	controller.Paused, controller.TimeScale = false, 2
+	controller.SetTime(0.5)
+	controller.Update(0.1)
+	check("seek", 0.7, 0.5)
+
+	controller.Mode, controller.TimeScale = PlayPingPong, 1
+	controller.SetTime(0.8)
+	controller.Update(0.4)
+	check("ping", 0.8, 1)
+	controller.Update(0.9)
+	check("pong", 0.1, 0.5, 0)
+
+	// 正好一轮时这一轮的事件都要触发
+	controller.Mode = PlayLoop
+	controller.Update(1)
+	check("loop duration", 0.1, 0.5, 1, 0)
+	controller.Update(2.25)
+	check("loop twice", 0.35, 0.5, 1, 0, 0.5, 1, 0)
+	controller.TimeScale = -1
+	controller.Update(1)
+	check("reverse duration", 0.35, 0, 1, 0.5)
+	controller.Mode, controller.TimeScale = PlayPingPong, 1
+	controller.Update(2)
+	check("ping pong duration", 0.35, 0.5, 1, 0.5, 0)
+
+	controller.Mode = PlayOnce
+	controller.SetTime(0.9)
+	controller.Update(5)
+	check("once", 1, 1)
+	controller.Update(1)
+	if check("hold", 1); !controller.IsFinished() {
+		t.Fatal("should finish at the end")
+	}
+	controller.TimeScale = -1
+	if controller.IsFinished() {
+		t.Fatal("reverse should play back to 0")
+	}
+	controller.Update(5)
+	if check("once reverse", 0, 0.5, 0); !controller.IsFinished() {
+		t.Fatal("should finish at 0")
+	}
+}
+
+// 约束保持文件中的顺序，Order 只决定更新顺序
+func TestParseIkConstraints(t *testing.T) {
+	w := NewSkelWriter()
+	writeInt(w, 2)
+	writeStr(w, "arm")
+	writeInt(w, 1) // order
+	writeBool(w, false)
+	writeInts(w, []int{1, 2})
+	writeInt(w, 3)
+	writeF4(w, 0.5)  // mix
+	writeF4(w, 2)    // softness
+	writeU8(w, 0xFF) // bendDirection -1
+	writeBool(w, false)
+	writeBool(w, true)
+	writeBool(w, false)
+	writeStr(w, "leg")
+	writeInt(w, 0)
+	writeBool(w, true)
+	writeInts(w, []int{4})
+	writeInt(w, 5)
+	writeF4(w, 1)
+	writeF4(w, 0)
+	writeU8(w, 1)
+	writeBool(w, true)
+	writeBool(w, false)
+	writeBool(w, true)
+	res := parseIkConstraints(NewSkelReader(bytes.NewReader(w.Buffer.Bytes())))
+	if len(res) != 2 {
+		t.Fatalf("invalid ik constraints %v", res)
+	}
+	arm, leg := res[0], res[1]
+	if arm.Name != "arm" || arm.Order != 1 || arm.SkinRequire || fmt.Sprint(arm.Bones) != "[1 2]" || arm.Target != 3 ||
+		arm.Mix != 0.5 || arm.Softness != 2 || arm.BendDirection != -1 || arm.Compress || !arm.Stretch || arm.Uniform {
+		t.Fatalf("invalid ik constraint %+v", arm)
+	}
+	if leg.Name != "leg" || leg.Order != 0 || !leg.SkinRequire || fmt.Sprint(leg.Bones) != "[4]" || leg.Target != 5 ||
+		leg.Mix != 1 || leg.Softness != 0 || leg.BendDirection != 1 || !leg.Compress || leg.Stretch || !leg.Uniform {
+		t.Fatalf("invalid ik constraint %+v", leg)
+	}
+}
+
+func TestParseIkTimeline(t *testing.T) {
+	w := NewSkelWriter()
+	writeStr(w, "walk")
+	writeInt(w, 0) // slot
+	writeInt(w, 0) // bone
+	writeInt(w, 1) // ik
+	writeInt(w, 1) // 第二个约束
+	writeInt(w, 2)
+	writeF4(w, 0)
+	writeF4(w, 1)
+	writeF4(w, 3)
+	writeU8(w, 0xFF)
+	writeBool(w, true)
+	writeBool(w, false)
+	writeU8(w, CurveStepped)
+	writeF4(w, 0.5)
+	writeF4(w, 0)
+	writeF4(w, 0)
+	writeU8(w, 1)
+	writeBool(w, false)
+	writeBool(w, true)
+	for i := 0; i < 5; i++ { // transform path deform drawOrder event
+		writeInt(w, 0)
+	}
+	anim := parseAnimation(NewSkelReader(bytes.NewReader(w.Buffer.Bytes())), nil, nil, nil, nil)
+	if anim.Name != "walk" || anim.Duration != 0.5 || len(anim.Timelines) != 1 {
+		t.Fatalf("invalid animation %+v", anim)
+	}
+	timeline := anim.Timelines[0]
+	if timeline.Type != TimelineIkConstraint || timeline.IkConstraint != 1 || len(timeline.KeyFrames) != 2 {
+		t.Fatalf("invalid timeline %+v", timeline)
+	}
+	first, last := timeline.KeyFrames[0], timeline.KeyFrames[1]
+	if first.Time != 0 || first.Mix != 1 || first.Softness != 3 || first.BendDirection != -1 || !first.Compress || first.Stretch ||
+		first.Curve == nil || first.Curve.Type != CurveStepped {
+		t.Fatalf("invalid key frame %+v", first)
+	}
+	if last.Time != 0.5 || last.Mix != 0 || last.BendDirection != 1 || last.Compress || !last.Stretch {
+		t.Fatalf("invalid key frame %+v", last)
+	}
+}
+
+// 事件的 int 是 zigzag 编码，与 spine-libgdx 3.8 readInt(false) 一致
+func TestParseEventIntZigZag(t *testing.T) {
+	data := []byte{
+		2,    // 两个事件
+		1, 5, // a，-3
+		0, 0, 0, 0, // float
+		0, 0, // string audio
+		2, 0x80, 1, // b，64 编码为 128 占两个字节
+		0, 0, 0, 0,
+		0, 0,
+	}
+	res := parseEvents(NewSkelReader(bytes.NewReader(data)), []string{"a", "b"})
+	if len(res) != 2 || res[0].Int != -3 || res[1].Int != 64 {
+		t.Fatalf("invalid event ints %+v %+v", res[0], res[1])
+	}
+}
+
+// 写入的事件 int 也是 zigzag 编码，读回后不变
+func TestEventIntZigZag(t *testing.T) {
+	events := []*EventData{{Name: "a", Int: -3}, {Name: "b", Int: 64}, {Name: "c", Int: math.MinInt32}}
+	w := NewSkelWriter()
+	writeEvents(w, events)
+	data := w.Buffer.Bytes()
+	// 数量 名称 -3，64 编码为 128 占两个字节
+	if !bytes.HasPrefix(data, []byte{3, 1, 5}) || !bytes.Contains(data, []byte{0x80, 1}) {
+		t.Fatalf("invalid zigzag bytes %v", data)
+	}
+	res := parseEvents(NewSkelReader(bytes.NewReader(data)), []string{"a", "b", "c"})
+	for i, item := range res {
+		if item.Int != events[i].Int {
+			t.Fatalf("event %s int = %d, want %d", item.Name, item.Int, events[i].Int)
+		}
+	}
+}
+
+// IK 子骨骼被之后的变换约束修改，下一帧不能用上一帧的世界数据覆盖局部数据
+func TestModifyResetEachFrame(t *testing.T) {
+	bones := []*Bone{
+		{Name: "root", Parent: -1, Scale: mgl32.Vec2{1, 1}},
+		{Name: "parent", Parent: 0, Scale: mgl32.Vec2{1, 1}, Length: 10},
+		{Name: "child", Parent: 1, Pos: mgl32.Vec2{10, 0}, Rotate: 20, Scale: mgl32.Vec2{1, 1}, Length: 10},
+		{Name: "ik", Parent: 0, Pos: mgl32.Vec2{5, 12}, Scale: mgl32.Vec2{1, 1}},
+		{Name: "target", Parent: 0, Rotate: 60, Scale: mgl32.Vec2{1, 1}},
+	}
+	nodes := NewBoneNodes(bones)
+	ik := NewIkConstraintState(&IkConstraint{Bones: []int{1, 2}, Target: 3, Mix: 0.5, BendDirection: 1})
+	transform := NewTransformConstraintState(&TransformConstraint{Order: 1, Bones: []int{2}, Target: 4, RotateMix: 1})
+	controller := NewConstraintController(nodes, []*IkConstraintState{ik}, nil, []*TransformConstraintState{transform})
+	poses := make([]string, 0)
+	for i := 0; i < 2; i++ {
+		for _, node := range nodes {
+			node.SetToSetupPose()
+		}
+		controller.Update()
+		poses = append(poses, fmt.Sprint(nodes[2].Mat2, nodes[2].WorldPos))
+	}
+	if poses[0] != poses[1] {
+		t.Fatalf("pose changed between frames: %v", poses)
+	}
+}
+
+// root -> parent(长度 10) -> child(在 (10, 0)，长度 10)，root -> target
+func newIkBones(item *IkConstraint, parentScale, target mgl32.Vec2) []*BoneNode {
+	bones := []*Bone{
+		{Name: "root", Parent: -1, Scale: mgl32.Vec2{1, 1}},
+		{Name: "parent", Parent: 0, Scale: parentScale, Length: 10},
+		{Name: "child", Parent: 1, Pos: mgl32.Vec2{10, 0}, Scale: mgl32.Vec2{1, 1}, Length: 10},
+		{Name: "target", Parent: 0, Pos: target, Scale: mgl32.Vec2{1, 1}},
+	}
+	item.Target = 3
+	nodes := NewBoneNodes(bones)
+	controller := NewConstraintController(nodes, []*IkConstraintState{NewIkConstraintState(item)}, nil, nil)
+	controller.Update()
+	return nodes
+}
+
+// 骨骼末端的世界坐标
+func boneTip(node *BoneNode) mgl32.Vec2 {
+	return node.Mat2.Mul2x1(mgl32.Vec2{node.Bone.Length, 0}).Add(node.WorldPos)
+}
+
+// IK 结果常有接近 0 的分量，使用绝对误差比较
+func checkIkBone(t *testing.T, bone *BoneNode, mat2 mgl32.Mat2, pos mgl32.Vec2) {
+	for i := range mat2 {
+		if mgl32.Abs(bone.Mat2[i]-mat2[i]) > 1e-4 || bone.WorldPos.Sub(pos).Len() > 1e-3 {
+			t.Errorf("%s world = %v %v, want %v %v", bone.Bone.Name, bone.Mat2, bone.WorldPos, mat2, pos)
+			return
+		}
+	}
+}
+
+func TestIkOneBone(t *testing.T) {
+	bones := newIkBones(&IkConstraint{Bones: []int{1}, Mix: 1}, mgl32.Vec2{1, 1}, mgl32.Vec2{0, 10})
+	checkIkBone(t, bones[1], GScaleMat.Mul2(Rotate(90)), mgl32.Vec2{})
+	bones = newIkBones(&IkConstraint{Bones: []int{1}, Mix: 0.5}, mgl32.Vec2{1, 1}, mgl32.Vec2{0, 10})
+	checkIkBone(t, bones[1], GScaleMat.Mul2(Rotate(45)), mgl32.Vec2{})
+	// 拉伸只缩放 x，uniform 时 y 一起缩放
+	bones = newIkBones(&IkConstraint{Bones: []int{1}, Mix: 1, Stretch: true}, mgl32.Vec2{1, 1}, mgl32.Vec2{0, 20})
+	checkIkBone(t, bones[1], GScaleMat.Mul2(Rotate(90)).Mul2(Scale(mgl32.Vec2{2, 1})), mgl32.Vec2{})
+	bones = newIkBones(&IkConstraint{Bones: []int{1}, Mix: 1, Stretch: true, Uniform: true}, mgl32.Vec2{1, 1}, mgl32.Vec2{0, 20})
+	checkIkBone(t, bones[1], GScaleMat.Mul2(Rotate(90)).Mul2(Scale(mgl32.Vec2{2, 2})), mgl32.Vec2{})
+	bones = newIkBones(&IkConstraint{Bones: []int{1}, Mix: 0.5, Stretch: true}, mgl32.Vec2{1, 1}, mgl32.Vec2{0, 20})
+	checkIkBone(t, bones[1], GScaleMat.Mul2(Rotate(45)).Mul2(Scale(mgl32.Vec2{1.5, 1})), mgl32.Vec2{})
+	// 压缩只在目标更近时生效，拉伸相反
+	bones = newIkBones(&IkConstraint{Bones: []int{1}, Mix: 1, Compress: true}, mgl32.Vec2{1, 1}, mgl32.Vec2{0, 5})
+	checkIkBone(t, bones[1], GScaleMat.Mul2(Rotate(90)).Mul2(Scale(mgl32.Vec2{0.5, 1})), mgl32.Vec2{})
+	bones = newIkBones(&IkConstraint{Bones: []int{1}, Mix: 1, Stretch: true}, mgl32.Vec2{1, 1}, mgl32.Vec2{0, 5})
+	checkIkBone(t, bones[1], GScaleMat.Mul2(Rotate(90)), mgl32.Vec2{})
+}
+
+// child 不继承 parent 的旋转，parent 旋转 90 度后 child 在 (0, 10)
+func TestIkNoRotationOrReflection(t *testing.T) {
+	bones := []*Bone{
+		{Name: "root", Parent: -1, Scale: mgl32.Vec2{1, 1}},
+		{Name: "parent", Parent: 0, Rotate: 90, Scale: mgl32.Vec2{1, 1}, Length: 10},
+		{Name: "child", Parent: 1, Pos: mgl32.Vec2{10, 0}, Scale: mgl32.Vec2{1, 1}, Length: 10, TransformMode: TransformNoRotationOrReflection},
+		{Name: "target", Parent: 0, Scale: mgl32.Vec2{1, 1}},
+	}
+	for _, item := range []struct {
+		target mgl32.Vec2
+		rotate float32
+	}{{mgl32.Vec2{10, 10}, 0}, {mgl32.Vec2{0, 20}, 90}, {mgl32.Vec2{-10, 0}, -135}} {
+		bones[3].Pos = item.target
+		nodes := NewBoneNodes(bones)
+		ik := NewIkConstraintState(&IkConstraint{Bones: []int{2}, Target: 3, Mix: 1})
+		NewConstraintController(nodes, []*IkConstraintState{ik}, nil, nil).Update()
+		checkIkBone(t, nodes[2], GScaleMat.Mul2(Rotate(item.rotate)), GScaleMat.Mul2x1(mgl32.Vec2{0, 10}))
+	}
+}
+
+func TestIkTwoBone(t *testing.T) {
+	target := mgl32.Vec2{10, 10}
+	// 两种弯曲方向的肘部分别在 (10, 0) 与 (0, 10)
+	bones := newIkBones(&IkConstraint{Bones: []int{1, 2}, Mix: 1, BendDirection: 1}, mgl32.Vec2{1, 1}, target)
+	checkIkBone(t, bones[1], GScaleMat, mgl32.Vec2{})
+	checkIkBone(t, bones[2], GScaleMat.Mul2(Rotate(90)), GScaleMat.Mul2x1(mgl32.Vec2{10, 0}))
+	bones = newIkBones(&IkConstraint{Bones: []int{1, 2}, Mix: 1, BendDirection: -1}, mgl32.Vec2{1, 1}, target)
+	checkIkBone(t, bones[1], GScaleMat.Mul2(Rotate(90)), mgl32.Vec2{})
+	checkIkBone(t, bones[2], GScaleMat, GScaleMat.Mul2x1(mgl32.Vec2{0, 10}))
+	// 够不到时伸直，拉伸只作用于父骨骼
+	bones = newIkBones(&IkConstraint{Bones: []int{1, 2}, Mix: 1, BendDirection: 1}, mgl32.Vec2{1, 1}, mgl32.Vec2{30, 0})
+	checkIkBone(t, bones[2], GScaleMat, GScaleMat.Mul2x1(mgl32.Vec2{10, 0}))
+	bones = newIkBones(&IkConstraint{Bones: []int{1, 2}, Mix: 1, BendDirection: 1, Stretch: true}, mgl32.Vec2{1, 1}, mgl32.Vec2{30, 0})
+	mat2 := GScaleMat.Mul2(Scale(mgl32.Vec2{1.5, 1}))
+	checkIkBone(t, bones[1], mat2, mgl32.Vec2{})
+	checkIkBone(t, bones[2], mat2, GScaleMat.Mul2x1(mgl32.Vec2{15, 0}))
+	// softness 2 时刚好够到的目标被拉近到 19.5，骨骼不会完全伸直
+	bones = newIkBones(&IkConstraint{Bones: []int{1, 2}, Mix: 1, BendDirection: 1, Softness: 2}, mgl32.Vec2{1, 1}, mgl32.Vec2{20, 0})
+	if tip := boneTip(bones[2]); !tip.ApproxEqualThreshold(GScaleMat.Mul2x1(mgl32.Vec2{19.5, 0}), 1e-3) {
+		t.Errorf("soft tip = %v", tip)
+	}
+	// mix 为 0 时保持初始姿势，子骨骼也要计算世界数据
+	bones = newIkBones(&IkConstraint{Bones: []int{1, 2}, Mix: 0, BendDirection: 1}, mgl32.Vec2{1, 1}, target)
+	checkIkBone(t, bones[2], GScaleMat, GScaleMat.Mul2x1(mgl32.Vec2{10, 0}))
+}
+
+// 根骨骼的世界坐标是局部坐标加偏移，不受全局缩放影响，反推与 IK 后位置不能漂移
+func TestRootAppliedTransform(t *testing.T) {
+	bones := []*Bone{
+		{Name: "root", Parent: -1, Pos: mgl32.Vec2{5, 6}, Rotate: 30, Scale: mgl32.Vec2{2, 1}},
+	}
+	nodes := NewBoneNodes(bones)
+	root := nodes[0]
+	root.Offset = mgl32.Vec2{100, 50}
+	root.updateTransform()
+	mat2, pos := root.Mat2, root.WorldPos
+	for i := 0; i < 2; i++ {
+		root.Modify = true
+		root.updateAppliedTransform()
+		root.updateTransform()
+	}
+	if !root.LocalPos.ApproxEqualThreshold(mgl32.Vec2{5, 6}, 1e-4) || mgl32.Abs(root.LocalRotate-30) > 1e-3 ||
+		!root.LocalScale.ApproxEqualThreshold(mgl32.Vec2{2, 1}, 1e-4) {
+		t.Errorf("root local = %v %v %v", root.LocalPos, root.LocalRotate, root.LocalScale)
+	}
+	checkIkBone(t, root, mat2, pos)
+	// 目标在根骨骼正上方 10 处，IK 后根骨骼旋转 90 度且位置不变
+	root.SetToSetupPose()
+	root.updateTransform()
+	controller := NewConstraintController(nodes, nil, nil, nil)
+	controller.applyIk1(root, GScaleMat.Mul2x1(mgl32.Vec2{0, 10}).Add(pos), false, false, false, 1)
+	checkIkBone(t, root, GScaleMat.Mul2(Rotate(90)).Mul2(Scale(mgl32.Vec2{2, 1})), pos)
+}
+
+func TestIkNonUniform(t *testing.T) {
+	// 父骨骼缩放 (1, 2)，子骨骼末端的轨迹是椭圆，能够到时末端在目标上
+	target := mgl32.Vec2{10, 10}
+	for _, bendDir := range []int{1, -1} {
+		bones := newIkBones(&IkConstraint{Bones: []int{1, 2}, Mix: 1, BendDirection: bendDir}, mgl32.Vec2{1, 2}, target)
+		if tip := boneTip(bones[2]); !tip.ApproxEqualThreshold(GScaleMat.Mul2x1(target), 1e-3) {
+			t.Errorf("bend %d tip = %v, want %v", bendDir, tip, GScaleMat.Mul2x1(target))
+		}
+	}
+	// 够不到时取椭圆上最远的点朝向目标
+	a1, a2 := solveIkNonUniform(10, 10, 20, 100, 0, 10000, 1, 2, 1)
+	angle := math.Acos(1.0 / 3)
+	want1 := -math.Atan2(20*math.Sin(angle), 10*math.Cos(angle)+10)
+	if math.Abs(float64(a1)-want1) > 1e-4 || math.Abs(float64(a2)-angle) > 1e-4 {
+		t.Fatalf("solve = %v %v, want %v %v", a1, a2, want1, angle)
+	}
+}
+
+// root -> a -> b -> c，IK 作用于 a b，变换约束作用于 b，Order 决定谁先生效
+func TestUpdateCacheOrder(t *testing.T) {
+	bones := []*Bone{
+		{Name: "root", Parent: -1, Scale: mgl32.Vec2{1, 1}},
+		{Name: "a", Parent: 0, Scale: mgl32.Vec2{1, 1}, Length: 10},
+		{Name: "b", Parent: 1, Pos: mgl32.Vec2{10, 0}, Scale: mgl32.Vec2{1, 1}, Length: 10},
+		{Name: "c", Parent: 2, Pos: mgl32.Vec2{10, 0}, Scale: mgl32.Vec2{1, 1}},
+		{Name: "ikTarget", Parent: 0, Scale: mgl32.Vec2{1, 1}},
+		{Name: "target", Parent: 0, Scale: mgl32.Vec2{1, 1}},
+	}
+	cacheNames := func(ikOrder, transformOrder int) string {
+		ik := NewIkConstraintState(&IkConstraint{Name: "ik", Order: ikOrder, Bones: []int{1, 2}, Target: 4, Mix: 1})
+		transform := NewTransformConstraintState(&TransformConstraint{Name: "transform", Order: transformOrder, Bones: []int{2}, Target: 5, RotateMix: 1})
+		controller := NewConstraintController(NewBoneNodes(bones), []*IkConstraintState{ik}, nil, []*TransformConstraintState{transform})
+		res := make([]string, 0)
+		for _, item := range controller.UpdateCache {
+			switch item := item.(type) {
+			case *BoneNode:
+				res = append(res, item.Bone.Name)
+			case *IkConstraintState:
+				res = append(res, item.IkConstraint.Name)
+			case *TransformConstraintState:
+				res = append(res, item.TransformConstraint.Name)
+			}
+		}
+		return strings.Join(res, " ")
+	}
+	// 变换约束先生效，IK 之后重新计算 b，c 在所有约束之后更新
+	if res := cacheNames(1, 0); res != "root target a b transform ikTarget ik c" {
+		t.Errorf("transform first: %s", res)
+	}
+	// IK 先生效，b 由 IK 计算不再单独加入
+	if res := cacheNames(0, 1); res != "root ikTarget a ik target transform c" {
+		t.Errorf("ik first: %s", res)
+	}
+}
+
+func TestSpineVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    SpineVersion
+		ok      bool
+	}{
+		{"3.8.99", SpineVersion{3, 8}, true},
+		{"4.1", SpineVersion{4, 1}, true},
+		{"4.10.2", SpineVersion{4, 10}, true},
+		{"4", SpineVersion{}, false},
+		{"x.8", SpineVersion{}, false},
+	}
+	for _, test := range tests {
+		if res, ok := parseVersion(test.version); res != test.want || ok != test.ok {
+			t.Errorf("parseVersion(%q) = %v %v", test.version, res, ok)
+		}
+	}
+	// 按数字比较，4.10 比 4.2 新
+	if version := (SpineVersion{4, 10}); !version.AtLeast(4, 2) || version.AtLeast(5, 0) || !version.AtLeast(3, 9) {
+		t.Fatal("invalid version compare")
+	}
+}
+
+// 4.x 的 x y mix 不同时 3.8 的数据无法表示，解析报错
+func TestTransformConstraintMixXY(t *testing.T) {
+	parse := func(mixes ...float32) (res []*TransformConstraint, err error) {
+		w := NewSkelWriter()
+		writeInt(w, 1)
+		writeStr(w, "transform")
+		writeInt(w, 0)
+		writeBool(w, false)
+		writeInts(w, []int{1})
+		writeInt(w, 0)
+		writeBool(w, false)
+		writeBool(w, false)
+		writeF4(w, 0)
+		writeVec2s(w, mgl32.Vec2{}, mgl32.Vec2{})
+		writeF4(w, 0)
+		for _, mix := range mixes {
+			writeF4(w, mix)
+		}
+		reader := NewSkelReader(bytes.NewReader(w.Buffer.Bytes()))
+		reader.Version = SpineVersion{4, 0}
+		defer recoverParseErr(&err, func() (string, int64) { return reader.Section, reader.Offset })
+		res = parseTransformConstraints(reader)
+		return
+	}
+	res, err := parse(1, 0.5, 0.5, 0.25, 0.25, 1)
+	if err != nil || res[0].RotateMix != 1 || res[0].OffsetMix != 0.5 || res[0].ScaleMix != 0.25 || res[0].ShearMix != 1 {
+		t.Fatalf("invalid transform constraint %+v %v", res, err)
+	}
+	for _, mixes := range [][]float32{{1, 0.5, 1, 1, 1, 1}, {1, 1, 1, 1, 0, 1}} {
+		if _, err = parse(mixes...); err == nil || !strings.Contains(err.Error(), "mix x") {
+			t.Fatal("should reject different x y mix", mixes, err)
+		}
+	}
+}
+
+// 4.x 有变化的分量曲线不同，或者贝塞尔曲线被其他分量的关键帧拆开时，3.8 的数据无法表示，解析报错
+func TestCurveChannels(t *testing.T) {
+	bezier := func(y1, y2 float32) *Curve {
+		return &Curve{Type: CurveBezier, Data: [2]mgl32.Vec2{{0.25, y1}, {0.75, y2}}}
+	}
+	linear := &Curve{Type: CurveLinear}
+	timeline := func(times []float32, values [][]float32, curves ...[]*Curve) *curveTimeline {
+		res := &curveTimeline{Values: values, Curves: append(curves, nil)}
+		for _, time := range times {
+			res.KeyFrames = append(res.KeyFrames, &KeyFrame{Time: time})
+		}
+		return res
+	}
+	convert := func(group *curveGroup) (err error) {
+		defer recoverParseErr(&err, func() (string, int64) { return "animation", 0 })
+		group.Merge([]float32{0, 0}).ToKeyFrames(func(keyFrame *KeyFrame, values []float32) {})
+		return
+	}
+	// x y 曲线相同或只有一个分量变化时保留曲线
+	group := &curveGroup{}
+	group.Add(timeline([]float32{0, 1}, [][]float32{{0, 0}, {10, 20}}, []*Curve{bezier(0, 1), bezier(0, 1.0001)}), 0, 1)
+	if err := convert(group); err != nil {
+		t.Fatal(err)
+	}
+	group = &curveGroup{}
+	group.Add(timeline([]float32{0, 1}, [][]float32{{0, 5}, {10, 5}}, []*Curve{bezier(0, 1), linear}), 0, 1)
+	if err := convert(group); err != nil {
+		t.Fatal(err)
+	}
+	// 拆开的线性曲线重新采样后不变
+	group = &curveGroup{}
+	group.Add(timeline([]float32{0, 1}, [][]float32{{0}, {10}}, []*Curve{linear}), 0)
+	group.Add(timeline([]float32{0, 0.5, 1}, [][]float32{{0}, {5}, {10}}, []*Curve{linear}, []*Curve{linear}), 1)
+	if err := convert(group); err != nil {
+		t.Fatal(err)
+	}
+	tests := []*curveGroup{{}, {}, {}}
+	tests[0].Add(timeline([]float32{0, 1}, [][]float32{{0, 0}, {10, 20}}, []*Curve{bezier(0, 1), bezier(0.5, 1)}), 0, 1)
+	tests[1].Add(timeline([]float32{0, 1}, [][]float32{{0}, {10}}, []*Curve{linear}), 0)
+	tests[1].Add(timeline([]float32{0, 1}, [][]float32{{0}, {10}}, []*Curve{{Type: CurveStepped}}), 1)
+	tests[2].Add(timeline([]float32{0, 1}, [][]float32{{0}, {10}}, []*Curve{bezier(0, 1)}), 0)
+	tests[2].Add(timeline([]float32{0, 0.5, 1}, [][]float32{{0}, {5}, {10}}, []*Curve{linear}, []*Curve{linear}), 1)
+	for i, group := range tests {
+		if err := convert(group); err == nil || !strings.Contains(err.Error(), "separate channel curves") {
+			t.Fatal("should reject different channel curves", i, err)
+		}
+	}
+}
+
+func TestAudioDir(t *testing.T) {
+	tests := []struct {
+		audioPath string
+		want      string
+	}{
+		{"", "res/char"},
+		{"./audio", "res/char/audio"},
+		{"..\\sounds\\", "res/sounds"},
+		{"C:\\spine\\audio", "res/char"}, // 编辑器所在机器的绝对路径
+		{"/home/spine/audio", "res/char"},
+	}
+	for _, test := range tests {
+		if res := audioDir("res/char/char.skel", &SkelHeader{AudioPath: test.audioPath}); res != test.want {
+			t.Errorf("audioDir(%q) = %q, want %q", test.audioPath, res, test.want)
+		}
+	}
+	if res := audioDir("char.skel", nil); res != "." {
+		t.Errorf("audioDir without header = %q", res)
+	}
+}
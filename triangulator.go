@@ -0,0 +1,144 @@
+package main
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"slices"
+)
+
+// 参考 spine-libgdx 3.8 Triangulator，耳切法三角化，polygon 需要先 makeClockwise
+func Triangulate(polygon []mgl32.Vec2) []uint16 {
+	indices := make([]uint16, len(polygon))
+	concaves := make([]bool, len(polygon))
+	for i := range indices {
+		indices[i] = uint16(i)
+	}
+	for i := range concaves {
+		concaves[i] = isConcave(i, polygon, indices)
+	}
+	res := make([]uint16, 0, max(0, len(polygon)-2)*3)
+	for len(indices) > 3 {
+		count := len(indices)
+		// 查找耳尖，三角形内不能包含其他凹点
+		prev, i, next := count-1, 0, 1
+		for {
+			if !concaves[i] && !containsConcave(polygon, indices, concaves, prev, i, next) {
+				break
+			}
+			if next == 0 { // 没有找到，退化情况取最后一个凸点
+				for ; i > 0 && concaves[i]; i-- {
+				}
+				break
+			}
+			prev, i, next = i, next, (next+1)%count
+		}
+		res = append(res, indices[(count+i-1)%count], indices[i], indices[(i+1)%count])
+		indices = slices.Delete(indices, i, i+1)
+		concaves = slices.Delete(concaves, i, i+1)
+		count--
+		prevIndex, nextIndex := (count+i-1)%count, i%count
+		concaves[prevIndex] = isConcave(prevIndex, polygon, indices)
+		concaves[nextIndex] = isConcave(nextIndex, polygon, indices)
+	}
+	if len(indices) == 3 {
+		res = append(res, indices[2], indices[0], indices[1])
+	}
+	return res
+}
+
+func containsConcave(polygon []mgl32.Vec2, indices []uint16, concaves []bool, prev, i, next int) bool {
+	p1, p2, p3 := polygon[indices[prev]], polygon[indices[i]], polygon[indices[next]]
+	for j := (next + 1) % len(indices); j != prev; j = (j + 1) % len(indices) {
+		if !concaves[j] {
+			continue
+		}
+		v := polygon[indices[j]]
+		if positiveArea(p3, p1, v) && positiveArea(p1, p2, v) && positiveArea(p2, p3, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// 把三角化结果合并为尽量少的凸多边形，减少裁剪次数
+func Decompose(polygon []mgl32.Vec2, triangles []uint16) [][]mgl32.Vec2 {
+	polygons := make([][]mgl32.Vec2, 0)
+	polygonsIndices := make([][]uint16, 0)
+	var curr []mgl32.Vec2
+	var currIndices []uint16
+	fanBase, lastWinding := -1, 0
+	// 相邻三角形共用起点且保持凸性时合并为扇形
+	for i := 0; i+2 < len(triangles); i += 3 {
+		t1, t2, t3 := triangles[i], triangles[i+1], triangles[i+2]
+		p1, p2, p3 := polygon[t1], polygon[t2], polygon[t3]
+		if fanBase == int(t1) {
+			o := len(curr) - 2
+			if winding(curr[o], curr[o+1], p3) == lastWinding && winding(p3, curr[0], curr[1]) == lastWinding {
+				curr = append(curr, p3)
+				currIndices = append(currIndices, t3)
+				continue
+			}
+		}
+		if len(curr) > 0 {
+			polygons = append(polygons, curr)
+			polygonsIndices = append(polygonsIndices, currIndices)
+		}
+		curr = []mgl32.Vec2{p1, p2, p3}
+		currIndices = []uint16{t1, t2, t3}
+		lastWinding = winding(p1, p2, p3)
+		fanBase = int(t1)
+	}
+	if len(curr) > 0 {
+		polygons = append(polygons, curr)
+		polygonsIndices = append(polygonsIndices, currIndices)
+	}
+	// 剩余的单个三角形尝试合并进已有的扇形
+	for i := range polygons {
+		indices := polygonsIndices[i]
+		if len(indices) == 0 {
+			continue
+		}
+		first, last := indices[0], indices[len(indices)-1]
+		o := len(polygons[i]) - 2
+		prevPrev, prev := polygons[i][o], polygons[i][o+1]
+		firstPoint, secondPoint := polygons[i][0], polygons[i][1]
+		currWinding := winding(prevPrev, prev, firstPoint)
+		for j := 0; j < len(polygons); j++ {
+			other := polygonsIndices[j]
+			if j == i || len(other) != 3 || other[0] != first || other[1] != last {
+				continue
+			}
+			p3 := polygons[j][2]
+			if winding(prevPrev, prev, p3) == currWinding && winding(p3, firstPoint, secondPoint) == currWinding {
+				polygons[j], polygonsIndices[j] = nil, nil
+				polygons[i] = append(polygons[i], p3)
+				polygonsIndices[i] = append(polygonsIndices[i], other[2])
+				last = other[2]
+				prevPrev, prev = prev, p3
+				j = 0
+			}
+		}
+	}
+	return slices.DeleteFunc(polygons, func(item []mgl32.Vec2) bool {
+		return len(item) == 0
+	})
+}
+
+func isConcave(index int, polygon []mgl32.Vec2, indices []uint16) bool {
+	count := len(indices)
+	prev := polygon[indices[(count+index-1)%count]]
+	curr := polygon[indices[index]]
+	next := polygon[indices[(index+1)%count]]
+	return !positiveArea(prev, curr, next)
+}
+
+func positiveArea(p1, p2, p3 mgl32.Vec2) bool {
+	return p1.X()*(p3.Y()-p2.Y())+p2.X()*(p1.Y()-p3.Y())+p3.X()*(p2.Y()-p1.Y()) >= 0
+}
+
+func winding(p1, p2, p3 mgl32.Vec2) int {
+	px, py := p2.X()-p1.X(), p2.Y()-p1.Y()
+	if p3.X()*py-p3.Y()*px+px*p1.Y()-p1.X()*py >= 0 {
+		return 1
+	}
+	return -1
+}
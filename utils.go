@@ -65,6 +65,11 @@ func AdjustRotate(val float32) float32 {
 	return val
 }
 
+// 返回角度
+func Atan2(y, x float32) float32 {
+	return float32(math.Atan2(float64(y), float64(x)) * 180 / math.Pi)
+}
+
 func Rotate(angle float32) mgl32.Mat2 {
 	return mgl32.HomogRotate2D(angle * math.Pi / 180).Mat2()
 }
@@ -73,25 +78,41 @@ func Scale(scale mgl32.Vec2) mgl32.Mat2 {
 	return mgl32.Scale2D(scale.X(), scale.Y()).Mat2()
 }
 
+// 局部变换矩阵 x 轴旋转 rotate+shear.x  y 轴旋转 rotate+90+shear.y  再分别缩放
+func TransformMat2(rotate float32, scale, shear mgl32.Vec2) mgl32.Mat2 {
+	rx := float64(mgl32.DegToRad(rotate + shear.X()))
+	ry := float64(mgl32.DegToRad(rotate + 90 + shear.Y()))
+	return mgl32.Mat2{
+		float32(math.Cos(rx)) * scale.X(), float32(math.Sin(rx)) * scale.X(),
+		float32(math.Cos(ry)) * scale.Y(), float32(math.Sin(ry)) * scale.Y(),
+	}
+}
+
 /*
 		Sx*cos , -Sy*sin
 		Sx*sin , Sy*cos
+		mgl32 为列主序 mat2[0] = Sx*cos  mat2[1] = Sx*sin  mat2[2] = -Sy*sin  mat2[3] = Sy*cos
 		角度提取 tan = Sx*sin / Sx*cos
 		缩放提取 Sx = sqrt((Sx*cos)^2 + (Sx*sin)^2)
 	            Sy = sqrt((-Sy*sin)^2 + (Sy*cos)^2)
 */
 func GetRotate(mat2 mgl32.Mat2) float32 {
-	if mat2[2]*mat2[2]+mat2[0]*mat2[0] > 0.0001 { // Sx^2 不为 0
-		return float32(math.Atan2(float64(mat2[2]), float64(mat2[0])) * 180 / math.Pi)
+	if mat2[1]*mat2[1]+mat2[0]*mat2[0] > 0.0001 { // Sx^2 不为 0
+		return float32(math.Atan2(float64(mat2[1]), float64(mat2[0])) * 180 / math.Pi)
 	} else { // Sx*2 太小了，使用 后面一对提取角度
-		return float32(math.Atan2(float64(-mat2[1]), float64(mat2[3])) * 180 / math.Pi)
+		return float32(math.Atan2(float64(-mat2[2]), float64(mat2[3])) * 180 / math.Pi)
 	}
 }
 
 // 返回的缩放是没有符号的
 func GetScale(mat2 mgl32.Mat2) mgl32.Vec2 {
 	return mgl32.Vec2{
-		float32(math.Sqrt(float64(mat2[0]*mat2[0] + mat2[2]*mat2[2]))),
-		float32(math.Sqrt(float64(mat2[1]*mat2[1] + mat2[3]*mat2[3]))),
+		float32(math.Sqrt(float64(mat2[0]*mat2[0] + mat2[1]*mat2[1]))),
+		float32(math.Sqrt(float64(mat2[2]*mat2[2] + mat2[3]*mat2[3]))),
 	}
 }
+
+// 骨骼世界矩阵中包含 GScaleMat，移除后才是 spine 中的世界缩放
+func GetWorldScale(mat2 mgl32.Mat2) mgl32.Vec2 {
+	return GetScale(GScaleMat.Inv().Mul2(mat2))
+}
@@ -0,0 +1,667 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"io"
+	"math"
+)
+
+// 二进制输出，引用字符串先记录到字符串表，正文写完后再与头部、字符串表一起输出
+type SkelWriter struct {
+	Buffer  *bytes.Buffer
+	Strings []string
+	Refs    map[string]int // 字符串在 Strings 中的位置 + 1，0 为 null
+}
+
+func NewSkelWriter() *SkelWriter {
+	return &SkelWriter{Buffer: &bytes.Buffer{}, Refs: make(map[string]int)}
+}
+
+// 写出 spine 3.8 二进制，与 LoadSkel 对应，重新解析的结果与 skel 一致
+// 二进制解析 twoColor 时暗色的蓝色通道被 alpha 覆盖，写出的为 ff
+func SaveSkel(w io.Writer, skel *SkeletonData) (err error) {
+	defer func() {
+		if temp := recover(); temp != nil {
+			err = fmt.Errorf("save skel: %v", temp)
+		}
+	}()
+	writer := NewSkelWriter()
+	nonessential := skel.Header.Nonessential
+	writeBones(writer, skel.Bones, nonessential)
+	writeSlots(writer, skel.Slots)
+	writeIkConstraints(writer, skel.IkConstraints)
+	writeTransformConstraints(writer, skel.TransformConstraints)
+	writePathConstraints(writer, skel.PathConstraints)
+	writeSkins(writer, skel.Skins, nonessential)
+	writeEvents(writer, skel.Events)
+	writeAnimations(writer, skel)
+	header := NewSkelWriter()
+	writeSkelHeader(header, skel.Header)
+	writeStrings(header, writer.Strings)
+	if _, err = header.Buffer.WriteTo(w); err != nil {
+		return err
+	}
+	_, err = writer.Buffer.WriteTo(w)
+	return err
+}
+
+func writeSkelHeader(writer *SkelWriter, header *SkelHeader) {
+	writeStr(writer, header.Hash)
+	writeStr(writer, exportVersion(header.Version))
+	writeVec2s(writer, header.Pos, header.Size)
+	writeBool(writer, header.Nonessential)
+	if header.Nonessential {
+		writeF4(writer, header.Fps)
+		writeStr(writer, header.ImagesPath)
+		writeStr(writer, header.AudioPath)
+	}
+}
+
+// 其他版本解析后已转换为 3.8 的数据，版本号也要改为 3.8
+func exportVersion(version string) string {
+	if temp, ok := parseVersion(version); ok && temp == (SpineVersion{3, 8}) {
+		return version
+	}
+	return "3.8.99"
+}
+
+func writeStrings(writer *SkelWriter, strings []string) {
+	writeInt(writer, len(strings))
+	for _, item := range strings {
+		writeStr(writer, item)
+	}
+}
+
+func writeBones(writer *SkelWriter, bones []*Bone, nonessential bool) {
+	writeInt(writer, len(bones))
+	for i, bone := range bones {
+		writeStr(writer, bone.Name)
+		if i > 0 {
+			writeInt(writer, bone.Parent)
+		}
+		writeF4(writer, bone.Rotate)
+		writeVec2s(writer, bone.Pos, bone.Scale, bone.Shear)
+		writeF4(writer, bone.Length)
+		writeU8(writer, bone.TransformMode)
+		writeBool(writer, bone.SkinRequire)
+		if nonessential {
+			writeClr(writer, bone.Color)
+		}
+	}
+}
+
+func writeSlots(writer *SkelWriter, slots []*Slot) {
+	writeInt(writer, len(slots))
+	for _, slot := range slots {
+		writeStr(writer, slot.Name)
+		writeInt(writer, slot.Bone)
+		writeClr(writer, slot.Color)
+		writeClr(writer, slot.DarkColor)
+		writeRefStr(writer, slot.Attachment)
+		writeU8(writer, slot.BlendMode)
+	}
+}
+
+// 约束保持文件中的顺序，与动画和皮肤中的下标一致
+func writeIkConstraints(writer *SkelWriter, constraints []*IkConstraint) {
+	writeInt(writer, len(constraints))
+	for _, item := range constraints {
+		writeStr(writer, item.Name)
+		writeInt(writer, item.Order)
+		writeBool(writer, item.SkinRequire)
+		writeInts(writer, item.Bones)
+		writeInt(writer, item.Target)
+		writeF4(writer, item.Mix)
+		writeF4(writer, item.Softness)
+		writeU8(writer, uint8(int8(item.BendDirection)))
+		writeBool(writer, item.Compress)
+		writeBool(writer, item.Stretch)
+		writeBool(writer, item.Uniform)
+	}
+}
+
+func writeTransformConstraints(writer *SkelWriter, constraints []*TransformConstraint) {
+	writeInt(writer, len(constraints))
+	for _, item := range constraints {
+		writeStr(writer, item.Name)
+		writeInt(writer, item.Order)
+		writeBool(writer, item.SkinRequire)
+		writeInts(writer, item.Bones)
+		writeInt(writer, item.Target)
+		writeBool(writer, item.Local)
+		writeBool(writer, item.Relative)
+		writeF4(writer, item.Rotate)
+		writeVec2s(writer, item.Offset, item.Scale)
+		writeF4(writer, item.ShearY)
+		writeF4(writer, item.RotateMix)
+		writeF4(writer, item.OffsetMix)
+		writeF4(writer, item.ScaleMix)
+		writeF4(writer, item.ShearMix)
+	}
+}
+
+func writePathConstraints(writer *SkelWriter, constraints []*PathConstraint) {
+	writeInt(writer, len(constraints))
+	for _, item := range constraints {
+		writeStr(writer, item.Name)
+		writeInt(writer, item.Order)
+		writeBool(writer, item.SkinRequire)
+		writeInts(writer, item.Bones)
+		writeInt(writer, item.Target)
+		writeU8(writer, item.PositionMode)
+		writeU8(writer, item.SpaceMode)
+		writeU8(writer, item.RotateMode)
+		writeF4(writer, item.Rotate)
+		writeF4(writer, item.Position)
+		writeF4(writer, item.Space)
+		writeF4(writer, item.RotateMix)
+		writeF4(writer, item.OffsetMix)
+	}
+}
+
+// 第一个为默认皮肤，只有附件，为空时只写入插槽数 0
+func writeSkins(writer *SkelWriter, skins []*Skin, nonessential bool) {
+	writeSkinAttachments(writer, skins[0], nonessential)
+	writeInt(writer, len(skins)-1)
+	for _, skin := range skins[1:] {
+		writeRefStr(writer, skin.Name)
+		writeInts(writer, skin.Bones)
+		writeInts(writer, skin.IkConstraints)
+		writeInts(writer, skin.TransformConstraints)
+		writeInts(writer, skin.PathConstraints)
+		writeSkinAttachments(writer, skin, nonessential)
+	}
+}
+
+// 附件按插槽分组，插槽按首次出现的顺序
+func writeSkinAttachments(writer *SkelWriter, skin *Skin, nonessential bool) {
+	slots := make([]int, 0)
+	attachments := make(map[int][]*Attachment)
+	for _, item := range skin.Attachments {
+		if attachments[item.Slot] == nil {
+			slots = append(slots, item.Slot)
+		}
+		attachments[item.Slot] = append(attachments[item.Slot], item)
+	}
+	writeInt(writer, len(slots))
+	for _, slot := range slots {
+		writeInt(writer, slot)
+		writeInt(writer, len(attachments[slot]))
+		for _, item := range attachments[slot] {
+			writeAttachment(writer, item, nonessential)
+		}
+	}
+}
+
+func writeAttachment(writer *SkelWriter, attachment *Attachment, nonessential bool) {
+	writeRefStr(writer, attachment.Name)
+	writeRefStr(writer, attachment.RealName)
+	writeU8(writer, attachment.Type)
+	name := attachment.RealName
+	if len(name) == 0 {
+		name = attachment.Name
+	}
+	path := attachment.Path
+	if path == name { // 与名称相同时为 null
+		path = ""
+	}
+	switch attachment.Type {
+	case AttachmentRegion:
+		writeRefStr(writer, path)
+		writeF4(writer, attachment.Rotate)
+		writeVec2s(writer, attachment.Pos, attachment.Scale, attachment.Size)
+		writeClr(writer, attachment.Color)
+	case AttachmentMesh:
+		writeRefStr(writer, path)
+		writeClr(writer, attachment.Color)
+		writeInt(writer, len(attachment.UVs))
+		writeVec2s(writer, attachment.UVs...)
+		writeU16s(writer, attachment.Indices)
+		writeVertices(writer, attachment)
+		writeInt(writer, attachment.HullLength)
+		if nonessential {
+			writeU16s(writer, attachment.Edges)
+			writeVec2s(writer, attachment.Size)
+		}
+	case AttachmentLinkMesh:
+		writeRefStr(writer, path)
+		writeClr(writer, attachment.Color)
+		writeRefStr(writer, attachment.ParentSkin)
+		writeRefStr(writer, attachment.ParentName)
+		writeBool(writer, attachment.InheritDeform)
+		if nonessential {
+			writeVec2s(writer, attachment.Size)
+		}
+	case AttachmentBoundBox:
+		writeInt(writer, vertexCount(attachment))
+		writeVertices(writer, attachment)
+		if nonessential {
+			writeClr(writer, attachment.Color)
+		}
+	case AttachmentPath:
+		writeBool(writer, attachment.Close)
+		writeBool(writer, attachment.ConstantSpeed)
+		writeInt(writer, vertexCount(attachment))
+		writeVertices(writer, attachment)
+		for _, item := range attachment.Lengths {
+			writeF4(writer, item)
+		}
+		if nonessential {
+			writeClr(writer, attachment.Color)
+		}
+	case AttachmentPoint:
+		writeF4(writer, attachment.Rotate)
+		writeVec2s(writer, attachment.Pos)
+		if nonessential {
+			writeClr(writer, attachment.Color)
+		}
+	case AttachmentClip:
+		writeInt(writer, attachment.EndSlot)
+		writeInt(writer, vertexCount(attachment))
+		writeVertices(writer, attachment)
+		if nonessential {
+			writeClr(writer, attachment.Color)
+		}
+	default:
+		panic(fmt.Errorf("unknown attachment type: %v", attachment.Type))
+	}
+}
+
+// 数量在外面写入，Mesh 的数量为 UV 数
+func writeVertices(writer *SkelWriter, attachment *Attachment) {
+	writeBool(writer, attachment.Weight)
+	if !attachment.Weight {
+		writeVec2s(writer, attachment.Vertices...)
+		return
+	}
+	for _, items := range attachment.WeightVertices {
+		writeInt(writer, len(items))
+		for _, item := range items {
+			writeInt(writer, item.Bone)
+			writeVec2s(writer, item.Offset)
+			writeF4(writer, item.Weight)
+		}
+	}
+}
+
+func writeEvents(writer *SkelWriter, events []*EventData) {
+	writeInt(writer, len(events))
+	for _, item := range events {
+		writeRefStr(writer, item.Name)
+		writeZigZagInt(writer, item.Int)
+		writeF4(writer, item.Float)
+		writeStr(writer, item.String)
+		writeStr(writer, item.AudioPath)
+		if len(item.AudioPath) > 0 {
+			writeF4(writer, item.Volume)
+			writeF4(writer, item.Balance)
+		}
+	}
+}
+
+func writeAnimations(writer *SkelWriter, skel *SkeletonData) {
+	writeInt(writer, len(skel.Animations))
+	for _, animation := range skel.Animations {
+		writeAnimation(writer, skel, animation)
+	}
+}
+
+// 时间线按 parseAnimation 的顺序分组，组内按首次出现的顺序
+func writeAnimation(writer *SkelWriter, skel *SkeletonData, animation *Animation) {
+	slots := &timelineGroup{}
+	bones := &timelineGroup{}
+	ik := make([]*Timeline, 0)
+	transform := make([]*Timeline, 0)
+	path := &timelineGroup{}
+	deform := &timelineGroup{}
+	drawOrder := make([]*KeyFrame, 0)
+	events := make([]*KeyFrame, 0)
+	for _, timeline := range animation.Timelines {
+		switch timeline.Type {
+		case TimelineAttachment, TimelineColor, TimelineTwoColor:
+			slots.Add(timeline.Slot, timeline)
+		case TimelineRotate, TimelineTranslate, TimelineScale, TimelineShear:
+			bones.Add(timeline.Bone, timeline)
+		case TimelineIkConstraint:
+			ik = append(ik, timeline)
+		case TimelineTransformConstraint:
+			transform = append(transform, timeline)
+		case TimelinePathConstraintPosition, TimelinePathConstraintSpace, TimelinePathConstraintMix:
+			path.Add(timeline.PathConstraint, timeline)
+		case TimelineDeform:
+			deform.Add(timeline.Skin, timeline)
+		case TimelineDrawOrder:
+			drawOrder = append(drawOrder, timeline.KeyFrames...)
+		case TimelineEvent:
+			events = append(events, timeline.KeyFrames...)
+		}
+	}
+	writeStr(writer, animation.Name)
+	// slot
+	writeInt(writer, len(slots.Keys))
+	for _, slot := range slots.Keys {
+		writeInt(writer, slot)
+		writeInt(writer, len(slots.Items[slot]))
+		for _, timeline := range slots.Items[slot] {
+			switch timeline.Type {
+			case TimelineAttachment:
+				writeU8(writer, SlotAttachment)
+				writeInt(writer, len(timeline.KeyFrames))
+				for _, keyFrame := range timeline.KeyFrames {
+					writeF4(writer, keyFrame.Time)
+					writeRefStr(writer, keyFrame.Attachment)
+				}
+			case TimelineColor:
+				writeU8(writer, SlotColor)
+				writeKeyFrames(writer, timeline.KeyFrames, func(keyFrame *KeyFrame) {
+					writeClr(writer, keyFrame.Color)
+				})
+			case TimelineTwoColor:
+				writeU8(writer, SlotTwoColor)
+				writeKeyFrames(writer, timeline.KeyFrames, func(keyFrame *KeyFrame) {
+					writeClr(writer, keyFrame.Color)
+					writeClr(writer, keyFrame.DarkColor)
+				})
+			}
+		}
+	}
+	// bone
+	writeInt(writer, len(bones.Keys))
+	for _, bone := range bones.Keys {
+		writeInt(writer, bone)
+		writeInt(writer, len(bones.Items[bone]))
+		for _, timeline := range bones.Items[bone] {
+			switch timeline.Type {
+			case TimelineRotate:
+				writeU8(writer, BoneRotate)
+				writeKeyFrames(writer, timeline.KeyFrames, func(keyFrame *KeyFrame) {
+					writeF4(writer, keyFrame.Rotate)
+				})
+			case TimelineTranslate:
+				writeU8(writer, BoneTranslate)
+				writeKeyFrames(writer, timeline.KeyFrames, func(keyFrame *KeyFrame) {
+					writeVec2s(writer, keyFrame.Offset)
+				})
+			case TimelineScale:
+				writeU8(writer, BoneScale)
+				writeKeyFrames(writer, timeline.KeyFrames, func(keyFrame *KeyFrame) {
+					writeVec2s(writer, keyFrame.Scale)
+				})
+			case TimelineShear:
+				writeU8(writer, BoneShear)
+				writeKeyFrames(writer, timeline.KeyFrames, func(keyFrame *KeyFrame) {
+					writeVec2s(writer, keyFrame.Shear)
+				})
+			}
+		}
+	}
+	// IK constraint
+	writeInt(writer, len(ik))
+	for _, timeline := range ik {
+		writeInt(writer, timeline.IkConstraint)
+		writeKeyFrames(writer, timeline.KeyFrames, func(keyFrame *KeyFrame) {
+			writeF4(writer, keyFrame.Mix)
+			writeF4(writer, keyFrame.Softness)
+			writeU8(writer, uint8(int8(keyFrame.BendDirection)))
+			writeBool(writer, keyFrame.Compress)
+			writeBool(writer, keyFrame.Stretch)
+		})
+	}
+	// Transform constraint
+	writeInt(writer, len(transform))
+	for _, timeline := range transform {
+		writeInt(writer, timeline.TransformConstraint)
+		writeKeyFrames(writer, timeline.KeyFrames, func(keyFrame *KeyFrame) {
+			writeF4(writer, keyFrame.RotateMix)
+			writeF4(writer, keyFrame.OffsetMix)
+			writeF4(writer, keyFrame.ScaleMix)
+			writeF4(writer, keyFrame.ShearMix)
+		})
+	}
+	// Path constraint
+	writeInt(writer, len(path.Keys))
+	for _, pathConstraint := range path.Keys {
+		writeInt(writer, pathConstraint)
+		writeInt(writer, len(path.Items[pathConstraint]))
+		for _, timeline := range path.Items[pathConstraint] {
+			switch timeline.Type {
+			case TimelinePathConstraintPosition:
+				writeU8(writer, PathPosition)
+				writeKeyFrames(writer, timeline.KeyFrames, func(keyFrame *KeyFrame) {
+					writeF4(writer, keyFrame.Position)
+				})
+			case TimelinePathConstraintSpace:
+				writeU8(writer, PathSpace)
+				writeKeyFrames(writer, timeline.KeyFrames, func(keyFrame *KeyFrame) {
+					writeF4(writer, keyFrame.Space)
+				})
+			case TimelinePathConstraintMix:
+				writeU8(writer, PathMix)
+				writeKeyFrames(writer, timeline.KeyFrames, func(keyFrame *KeyFrame) {
+					writeF4(writer, keyFrame.RotateMix)
+					writeF4(writer, keyFrame.OffsetMix)
+				})
+			}
+		}
+	}
+	// Deform 按 skin slot attachment 分组
+	writeInt(writer, len(deform.Keys))
+	for _, skin := range deform.Keys {
+		writeInt(writer, skin-skinIndexOffset(skel.Skins))
+		slots = &timelineGroup{}
+		for _, timeline := range deform.Items[skin] {
+			slots.Add(timeline.Slot, timeline)
+		}
+		writeInt(writer, len(slots.Keys))
+		for _, slot := range slots.Keys {
+			writeInt(writer, slot)
+			writeInt(writer, len(slots.Items[slot]))
+			for _, timeline := range slots.Items[slot] {
+				writeRefStr(writer, timeline.Attachment)
+				writeKeyFrames(writer, timeline.KeyFrames, func(keyFrame *KeyFrame) {
+					writeDeform(writer, keyFrame)
+				})
+			}
+		}
+	}
+	// Draw order
+	writeInt(writer, len(drawOrder))
+	for _, keyFrame := range drawOrder {
+		writeF4(writer, keyFrame.Time)
+		writeDrawOrder(writer, keyFrame.DrawOrder)
+	}
+	// Event
+	writeInt(writer, len(events))
+	for _, keyFrame := range events {
+		event := keyFrame.Event
+		writeF4(writer, keyFrame.Time)
+		writeInt(writer, indexOfEvent(skel.Events, event.Data))
+		writeZigZagInt(writer, event.Int)
+		writeF4(writer, event.Float)
+		writeBool(writer, event.String != event.Data.String)
+		if event.String != event.Data.String { // 有自己的字符串才写入
+			writeStr(writer, event.String)
+		}
+		if len(event.Data.AudioPath) > 0 {
+			writeF4(writer, event.Volume)
+			writeF4(writer, event.Balance)
+		}
+	}
+}
+
+// 先写帧数，每帧写入时间与数值，除最后一帧外都有曲线
+func writeKeyFrames(writer *SkelWriter, keyFrames []*KeyFrame, write func(keyFrame *KeyFrame)) {
+	writeInt(writer, len(keyFrames))
+	for i, keyFrame := range keyFrames {
+		writeF4(writer, keyFrame.Time)
+		write(keyFrame)
+		if i < len(keyFrames)-1 {
+			writeCurve(writer, keyFrame.Curve)
+		}
+	}
+}
+
+func writeCurve(writer *SkelWriter, curve *Curve) {
+	if curve == nil {
+		writeU8(writer, CurveLinear)
+		return
+	}
+	writeU8(writer, curve.Type)
+	if curve.Type == CurveBezier {
+		writeVec2s(writer, curve.Data[:]...)
+	}
+}
+
+// 与 newDeformKeyFrame 对应，只写入第一个到最后一个非 0 的偏移
+func writeDeform(writer *SkelWriter, keyFrame *KeyFrame) {
+	deform := keyFrame.Deform
+	if keyFrame.Weight {
+		deform = make([]mgl32.Vec2, 0)
+		for _, items := range keyFrame.WeightDeform {
+			deform = append(deform, items...)
+		}
+	}
+	values := make([]float32, 0)
+	for _, item := range deform {
+		values = append(values, item.X(), item.Y())
+	}
+	start, end := 0, len(values)
+	for start < end && values[start] == 0 {
+		start++
+	}
+	for end > start && values[end-1] == 0 {
+		end--
+	}
+	writeInt(writer, end-start)
+	if start == end {
+		return
+	}
+	writeInt(writer, start)
+	for _, item := range values[start:end] {
+		writeF4(writer, item)
+	}
+}
+
+// 与 newDrawOrder 对应，写入位置有变化插槽的偏移
+func writeDrawOrder(writer *SkelWriter, drawOrder []int) {
+	count := 0
+	for slot, order := range drawOrder {
+		if order != slot {
+			count++
+		}
+	}
+	writeInt(writer, count)
+	for slot, order := range drawOrder {
+		if order != slot {
+			writeInt(writer, slot)
+			writeInt(writer, order-slot)
+		}
+	}
+}
+
+func indexOfEvent(events []*EventData, data *EventData) int {
+	for i, item := range events {
+		if item == data {
+			return i
+		}
+	}
+	panic(fmt.Errorf("not find event %s", data.Name))
+}
+
+// 按下标分组的时间线，下标按首次出现的顺序
+type timelineGroup struct {
+	Keys  []int
+	Items map[int][]*Timeline
+}
+
+func (g *timelineGroup) Add(key int, timeline *Timeline) {
+	if g.Items == nil {
+		g.Items = make(map[int][]*Timeline)
+	}
+	if g.Items[key] == nil {
+		g.Keys = append(g.Keys, key)
+	}
+	g.Items[key] = append(g.Items[key], timeline)
+}
+
+func writeInts(writer *SkelWriter, values []int) {
+	writeInt(writer, len(values))
+	for _, item := range values {
+		writeInt(writer, item)
+	}
+}
+
+func writeU16s(writer *SkelWriter, values []uint16) {
+	writeInt(writer, len(values))
+	for _, item := range values {
+		writeU16(writer, item)
+	}
+}
+
+func writeU16(writer *SkelWriter, value uint16) {
+	writer.Buffer.Write(binary.BigEndian.AppendUint16(nil, value))
+}
+
+// 负数按 uint32 写入，读取时再转回 int32
+func writeInt(writer *SkelWriter, value int) {
+	temp := uint32(value)
+	for temp >= 128 {
+		writeU8(writer, uint8(temp&127|128))
+		temp >>= 7
+	}
+	writeU8(writer, uint8(temp))
+}
+
+func writeZigZagInt(writer *SkelWriter, value int) {
+	writeInt(writer, int(uint32(value<<1)^uint32(value>>31)))
+}
+
+func writeRefStr(writer *SkelWriter, value string) {
+	if len(value) == 0 {
+		writeInt(writer, 0)
+		return
+	}
+	idx, ok := writer.Refs[value]
+	if !ok {
+		writer.Strings = append(writer.Strings, value)
+		idx = len(writer.Strings)
+		writer.Refs[value] = idx
+	}
+	writeInt(writer, idx)
+}
+
+func writeClr(writer *SkelWriter, color mgl32.Vec4) {
+	writer.Buffer.Write([]byte{colorByte(color[0]), colorByte(color[1]), colorByte(color[2]), colorByte(color[3])})
+}
+
+func writeF4(writer *SkelWriter, value float32) {
+	writer.Buffer.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(value)))
+}
+
+func writeVec2s(writer *SkelWriter, values ...mgl32.Vec2) {
+	for _, item := range values {
+		writeF4(writer, item.X())
+		writeF4(writer, item.Y())
+	}
+}
+
+// 长度为字符数 + 1，与 readStr 对应
+func writeStr(writer *SkelWriter, value string) {
+	writeInt(writer, len(value)+1) // utf8 字节数 +1
+	writer.Buffer.WriteString(value)
+}
+
+func writeBool(writer *SkelWriter, value bool) {
+	if value {
+		writeU8(writer, 1)
+	} else {
+		writeU8(writer, 0)
+	}
+}
+
+func writeU8(writer *SkelWriter, value uint8) {
+	writer.Buffer.WriteByte(value)
+}